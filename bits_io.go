@@ -34,9 +34,39 @@ func (p *bitsReader) ReadBits(n uint) (v uint32, ok bool) {
 	return rv, true
 }
 
+// skipBits discards the first n bits of a newly created reader.
+func (p *bitsReader) skipBits(n uint) {
+	p.off += int(n / 8)
+	if n%8 != 0 {
+		p.ReadBits(n % 8)
+	}
+}
+
 // flushBits discards the unread bits in the buffer used by readBits.
 // It is used at the end of a line.
 func (p *bitsReader) flushBits() {
 	p.v = 0
 	p.nbits = 0
 }
+
+// reverseBitsTable maps a byte to the same byte with its bit order reversed.
+var reverseBitsTable = func() (t [256]byte) {
+	for i := range t {
+		var v byte
+		for b := 0; b < 8; b++ {
+			if i&(1<<uint(b)) != 0 {
+				v |= 0x80 >> uint(b)
+			}
+		}
+		t[i] = v
+	}
+	return
+}()
+
+// reverseBits reverses the bit order of every byte in data, in place.
+// It converts between FillOrder=2 (LSB-first) and FillOrder=1 (MSB-first).
+func reverseBits(data []byte) {
+	for i, v := range data {
+		data[i] = reverseBitsTable[v]
+	}
+}
@@ -55,17 +55,27 @@ Loop:
 // Decode reads a TIFF image from r and returns it as an image.Image.
 // The type of Image returned depends on the contents of the TIFF.
 func Decode(r io.Reader) (m image.Image, err error) {
+	return DecodeWithOptions(r, nil)
+}
+
+// DecodeWithOptions reads the first TIFF image from r using opt.
+func DecodeWithOptions(r io.Reader, opt *DecodeOptions) (m image.Image, err error) {
 	var p *Reader
 	if p, err = OpenReader(r); err != nil {
 		return
 	}
 	defer p.Close()
 
-	m, err = p.DecodeImage(0, 0)
+	m, err = p.DecodeImageWithOptions(0, 0, opt)
 	return
 }
 
 func DecodeAll(r io.Reader) (m [][]image.Image, errors [][]error, err error) {
+	return DecodeAllWithOptions(r, nil)
+}
+
+// DecodeAllWithOptions reads all the TIFF images from r using opt.
+func DecodeAllWithOptions(r io.Reader, opt *DecodeOptions) (m [][]image.Image, errors [][]error, err error) {
 	var p *Reader
 	if p, err = OpenReader(r); err != nil {
 		return
@@ -80,7 +90,7 @@ Loop:
 		m[i] = make([]image.Image, p.SubImageNum(i))
 		errors[i] = make([]error, len(p.Ifd[i]))
 		for j := 0; j < p.SubImageNum(i); j++ {
-			if m[i][j], errors[i][j] = p.DecodeImage(i, j); errors[i][j] != nil {
+			if m[i][j], errors[i][j] = p.DecodeImageWithOptions(i, j, opt); errors[i][j] != nil {
 				break Loop
 			}
 		}
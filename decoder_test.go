@@ -6,10 +6,13 @@ package tiff
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	_ "image/png"
+	"io"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -59,7 +62,7 @@ func TestUnpackBits(t *testing.T) {
 		"\xaa\xaa\xaa\x80\x00\x2a\xaa\xaa\xaa\xaa\x80\x00\x2a\x22\xaa\xaa\xaa\xaa\xaa\xaa\xaa\xaa\xaa\xaa",
 	}}
 	for _, u := range unpackBitsTests {
-		buf, err := TagValue_CompressionType_PackBits.Decode(strings.NewReader(u.compressed), 0, 0)
+		buf, _, err := TagValue_CompressionType_PackBits.Decode(strings.NewReader(u.compressed), 0, 0, nil)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -183,6 +186,713 @@ func TestDecompress(t *testing.T) {
 	}
 }
 
+// TestDecodeFillOrder tests that CCITT data stored with FillOrder=2
+// (LSB-first) is decoded into a mostly white fax page.
+func TestDecodeFillOrder(t *testing.T) {
+	f, err := os.Open(testdataDir + "www.fileformat.info/CCITT_1.TIF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	m, err := Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Bounds(), image.Rect(0, 0, 1728, 2376); got != want {
+		t.Fatalf("bounds: want %v, got %v", want, got)
+	}
+	gray, ok := m.(*image.Gray)
+	if !ok {
+		t.Fatalf("want *image.Gray, got %T", m)
+	}
+	var white int
+	for _, v := range gray.Pix {
+		if v == 0xff {
+			white++
+		}
+	}
+	if white < len(gray.Pix)*8/10 {
+		t.Fatalf("want a mostly white page, got %d/%d white pixels", white, len(gray.Pix))
+	}
+}
+
+// TestApplyOrientation tests all eight orientations on a 3x2 image.
+func TestApplyOrientation(t *testing.T) {
+	// Stored pixels:
+	//	1 2 3
+	//	4 5 6
+	src := image.NewGray(image.Rect(0, 0, 3, 2))
+	copy(src.Pix, []byte{1, 2, 3, 4, 5, 6})
+
+	var orientationTests = []struct {
+		o    TagValue_OrientationType
+		w, h int
+		pix  []byte
+	}{
+		{TagValue_OrientationType_TopLeft, 3, 2, []byte{1, 2, 3, 4, 5, 6}},
+		{TagValue_OrientationType_TopRight, 3, 2, []byte{3, 2, 1, 6, 5, 4}},
+		{TagValue_OrientationType_BottomRight, 3, 2, []byte{6, 5, 4, 3, 2, 1}},
+		{TagValue_OrientationType_BottomLeft, 3, 2, []byte{4, 5, 6, 1, 2, 3}},
+		{TagValue_OrientationType_LeftTop, 2, 3, []byte{1, 4, 2, 5, 3, 6}},
+		{TagValue_OrientationType_RightTop, 2, 3, []byte{4, 1, 5, 2, 6, 3}},
+		{TagValue_OrientationType_RightBottom, 2, 3, []byte{6, 3, 5, 2, 4, 1}},
+		{TagValue_OrientationType_LeftBottom, 2, 3, []byte{3, 6, 2, 5, 1, 4}},
+	}
+	for _, v := range orientationTests {
+		m := ApplyOrientation(src, v.o).(*image.Gray)
+		if got, want := m.Bounds(), image.Rect(0, 0, v.w, v.h); got != want {
+			t.Fatalf("%v: bounds: want %v, got %v", v.o, want, got)
+		}
+		if !bytes.Equal(m.Pix, v.pix) {
+			t.Fatalf("%v: want %v, got %v", v.o, v.pix, m.Pix)
+		}
+	}
+}
+
+// TestDecodeOrientation tests that images stored in all eight orientations
+// have the same display bounds when ApplyOrientation is set.
+func TestDecodeOrientation(t *testing.T) {
+	var orientations = []TagValue_OrientationType{
+		TagValue_OrientationType_LeftTop,
+		TagValue_OrientationType_RightTop,
+		TagValue_OrientationType_LeftBottom,
+		TagValue_OrientationType_RightBottom,
+		TagValue_OrientationType_TopLeft,
+		TagValue_OrientationType_TopRight,
+		TagValue_OrientationType_BottomLeft,
+		TagValue_OrientationType_BottomRight,
+	}
+	for i, want := range orientations {
+		name := fmt.Sprintf("%sgeotiff/zi_imaging/image%d.tif", testdataDir, i)
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := p.Ifd[0][0].Orientation(); got != want {
+			t.Fatalf("%s: want %v, got %v", name, want, got)
+		}
+		m, err := p.DecodeImageWithOptions(0, 0, &DecodeOptions{ApplyOrientation: true})
+		p.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, want := m.Bounds(), image.Rect(0, 0, 765, 380); got != want {
+			t.Fatalf("%s: bounds: want %v, got %v", name, want, got)
+		}
+	}
+}
+
+func loadWithOptions(name string, opt *DecodeOptions) (image.Image, error) {
+	f, err := os.Open(testdataDir + name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeWithOptions(f, opt)
+}
+
+func TestDecodeOptions(t *testing.T) {
+	want, err := load("video-001.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Force8Bit
+	m, err := loadWithOptions("video-001-16bit.tiff", &DecodeOptions{Force8Bit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*image.RGBA); !ok {
+		t.Fatalf("Force8Bit: got %T, want *image.RGBA", m)
+	}
+	compare(t, want, m)
+
+	// ExpandPalette
+	m0, err := load("video-001-paletted.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = loadWithOptions("video-001-paletted.tiff", &DecodeOptions{ExpandPalette: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.(*image.RGBA); !ok {
+		t.Fatalf("ExpandPalette: got %T, want *image.RGBA", m)
+	}
+	compare(t, m0, m)
+
+	// Rect, crossing tile borders.
+	for _, name := range []string{"video-001-tile-64x64.tiff", "video-001-strip-64.tiff", "video-001-gray.tiff"} {
+		full, err := load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := image.Rect(30, 50, 100, 90)
+		m, err = loadWithOptions(name, &DecodeOptions{Rect: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Bounds() != r {
+			t.Fatalf("%s: bounds = %v, want %v", name, m.Bounds(), r)
+		}
+		compare(t, full.(interface {
+			SubImage(image.Rectangle) image.Image
+		}).SubImage(r), m)
+	}
+	if _, err = loadWithOptions("video-001.tiff", &DecodeOptions{Rect: image.Rect(1000, 1000, 1010, 1010)}); err == nil {
+		t.Fatal("Rect outside of the image: got nil error")
+	}
+
+	// MaxPixels
+	b := want.Bounds()
+	if _, err = loadWithOptions("video-001.tiff", &DecodeOptions{MaxPixels: int64(b.Dx()*b.Dy()) - 1}); err == nil {
+		t.Fatal("MaxPixels: got nil error")
+	}
+	if _, err = loadWithOptions("video-001.tiff", &DecodeOptions{MaxPixels: int64(b.Dx() * b.Dy())}); err != nil {
+		t.Fatal(err)
+	}
+
+	// KeepRawSamples
+	m, err = loadWithOptions("video-001-gray-16bit.tiff", &DecodeOptions{KeepRawSamples: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, ok := m.(*MemPImage)
+	if !ok {
+		t.Fatalf("KeepRawSamples: got %T, want *MemPImage", m)
+	}
+	if raw.Channels() != 1 || raw.DataType() != reflect.Uint16 {
+		t.Fatalf("KeepRawSamples: got %d channels of %v", raw.Channels(), raw.DataType())
+	}
+	m0, err = load("video-001-gray-16bit.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, m0, raw)
+}
+
+// seekRecorder records the offsets passed to Seek and ReadAt.
+type seekRecorder struct {
+	*bytes.Reader
+	offsets map[int64]bool
+}
+
+func (p *seekRecorder) Seek(offset int64, whence int) (int64, error) {
+	p.offsets[offset] = true
+	return p.Reader.Seek(offset, whence)
+}
+
+func (p *seekRecorder) ReadAt(data []byte, offset int64) (int, error) {
+	p.offsets[offset] = true
+	return p.Reader.ReadAt(data, offset)
+}
+
+func TestDecodeRegion(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001-tile-64x64.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs := &seekRecorder{Reader: bytes.NewReader(data), offsets: map[int64]bool{}}
+	p, err := OpenReader(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// Only the tile at col=1, row=0 intersects r.
+	r := image.Rect(70, 10, 120, 50)
+	m, err := p.DecodeRegion(0, 0, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != r {
+		t.Fatalf("bounds = %v, want %v", m.Bounds(), r)
+	}
+	compare(t, full.(*image.RGBA).SubImage(r), m)
+
+	ifd := p.Ifd[0][0]
+	for col := 0; col < ifd.BlocksAcross(); col++ {
+		for row := 0; row < ifd.BlocksDown(); row++ {
+			read := rs.offsets[ifd.BlockOffset(col, row)]
+			if want := col == 1 && row == 0; read != want {
+				t.Fatalf("block %d/%d: read = %v, want %v", col, row, read, want)
+			}
+		}
+	}
+
+	// A region past the image edge keeps its bounds.
+	r = image.Rect(140, 90, 170, 120)
+	if m, err = p.DecodeRegion(0, 0, r); err != nil {
+		t.Fatal(err)
+	}
+	if m.Bounds() != r {
+		t.Fatalf("bounds = %v, want %v", m.Bounds(), r)
+	}
+	compare(t, full.(*image.RGBA).SubImage(r), m.(*image.RGBA).SubImage(full.Bounds()))
+}
+
+func TestReaderLevels(t *testing.T) {
+	for _, v := range []struct {
+		name   string
+		levels []Level
+	}{
+		{"BigTIFFSamples/BigTIFFSubIFD4.tif", []Level{
+			{I: 0, J: 0, Width: 64, Height: 64, ScaleX: 1, ScaleY: 1},
+			{I: 0, J: 1, Width: 32, Height: 32, ScaleX: 2, ScaleY: 2},
+		}},
+		{"gdal_autotest/gcore/data/test_with_mask_1bit_and_ovr.tif", []Level{
+			{I: 0, J: 0, Width: 20, Height: 20, ScaleX: 1, ScaleY: 1},
+			{I: 1, J: 0, Width: 10, Height: 10, ScaleX: 2, ScaleY: 2},
+		}},
+	} {
+		f, err := os.Open(testdataDir + v.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		p, err := OpenReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		levels, err := p.Levels(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(levels, v.levels) {
+			t.Fatalf("%s: levels = %v, want %v", v.name, levels, v.levels)
+		}
+
+		for _, x := range []struct {
+			width, height int
+			want          Level
+		}{
+			{1, 1, v.levels[1]},
+			{v.levels[1].Width, v.levels[1].Height, v.levels[1]},
+			{v.levels[1].Width + 1, v.levels[1].Height, v.levels[0]},
+			{1000, 1000, v.levels[0]},
+		} {
+			level, err := p.BestLevel(0, x.width, x.height)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if level != x.want {
+				t.Fatalf("%s: BestLevel(%d, %d) = %v, want %v", v.name, x.width, x.height, level, x.want)
+			}
+		}
+
+		// A missing IFD ends the chain of overviews.
+		var want []Level
+		for _, level := range v.levels {
+			if level.I == 0 {
+				want = append(want, level)
+			}
+		}
+		for _, ifds := range [][]*IFD{{}, {nil}} {
+			q := &Reader{Ifd: [][]*IFD{p.Ifd[0], ifds}}
+			levels, err := q.Levels(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(levels, want) {
+				t.Fatalf("%s: levels = %v, want %v", v.name, levels, want)
+			}
+			if _, err := q.Levels(1); err == nil {
+				t.Fatalf("%s: Levels(1): got no error for a missing IFD", v.name)
+			}
+		}
+	}
+}
+
+func TestDecodeWorkers(t *testing.T) {
+	for _, name := range []string{"video-001-tile-64x64.tiff", "video-001-strip-64.tiff", "video-001-16bit.tiff"} {
+		want, err := load(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, workers := range []int{1, 2, 16} {
+			m, err := loadWithOptions(name, &DecodeOptions{Workers: workers})
+			if err != nil {
+				t.Fatal(err)
+			}
+			compare(t, want, m)
+		}
+	}
+}
+
+// readSeekerOnly hides the io.ReaderAt of a reader.
+type readSeekerOnly struct {
+	io.ReadSeeker
+}
+
+func TestConcurrentDecodeImageBlock(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001-tile-64x64.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []io.Reader{
+		bytes.NewReader(data),
+		readSeekerOnly{bytes.NewReader(data)},
+		bytes.NewBuffer(data),
+	} {
+		p, err := OpenReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		across, down := p.ImageBlocksAcross(0, 0), p.ImageBlocksDown(0, 0)
+
+		want := make([]image.Image, across*down)
+		for k := range want {
+			if want[k], err = p.DecodeImageBlock(0, 0, k%across, k/across); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		errc := make(chan error, 8)
+		for g := 0; g < cap(errc); g++ {
+			go func(g int) {
+				for n := 0; n < 4*len(want); n++ {
+					k := (n + g) % len(want)
+					m, err := p.DecodeImageBlock(0, 0, k%across, k/across)
+					if err != nil {
+						errc <- err
+						return
+					}
+					if !bytes.Equal(m.(*image.RGBA).Pix, want[k].(*image.RGBA).Pix) {
+						errc <- fmt.Errorf("%T: block %d differs", r, k)
+						return
+					}
+				}
+				errc <- nil
+			}(g)
+		}
+		for g := 0; g < cap(errc); g++ {
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+		}
+		p.Close()
+	}
+}
+
+// countingReaderAt counts the calls and the bytes read by ReadAt.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	calls int
+	bytes int
+}
+
+func (p *countingReaderAt) ReadAt(data []byte, off int64) (n int, err error) {
+	n, err = p.r.ReadAt(data, off)
+	p.calls++
+	p.bytes += n
+	return
+}
+
+func TestOpenReaderAt(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001-tile-64x64.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := &countingReaderAt{r: bytes.NewReader(data)}
+	p, err := OpenReaderAt(ra, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if ra.calls > 3 {
+		t.Fatalf("OpenReaderAt: %d reads, want at most 3", ra.calls)
+	}
+
+	// A single tile is fetched with a single read of its size.
+	ifd := p.Ifd[0][0]
+	ra.calls, ra.bytes = 0, 0
+	r := ifd.BlockBounds(1, 1).Intersect(ifd.Bounds())
+	m, err := p.DecodeRegion(0, 0, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, want.(*image.RGBA).SubImage(r), m)
+	if n := ifd.BlockCount(1, 1); ra.calls != 1 || int64(ra.bytes) != n {
+		t.Fatalf("DecodeRegion: %d reads of %d bytes, want 1 read of %d bytes", ra.calls, ra.bytes, n)
+	}
+
+	// The tiles are contiguous, they are fetched at once.
+	ra.calls, ra.bytes = 0, 0
+	if m, err = p.DecodeImage(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, want, m)
+	if ra.calls != 1 {
+		t.Fatalf("DecodeImage: %d reads, want 1", ra.calls)
+	}
+
+	// DecodeImageBlock reads the block only.
+	ra.calls, ra.bytes = 0, 0
+	if _, err = p.DecodeImageBlock(0, 0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := ifd.BlockCount(2, 0); ra.calls != 1 || int64(ra.bytes) != n {
+		t.Fatalf("DecodeImageBlock: %d reads of %d bytes, want 1 read of %d bytes", ra.calls, ra.bytes, n)
+	}
+}
+
+func TestOpenReaderAtLazy(t *testing.T) {
+	// 4096 tiles and a large XMP packet.
+	img := image.NewGray(image.Rect(0, 0, 1024, 1024))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	var opt Options
+	opt.TagSetter().SetTileWidth(16)
+	opt.TagSetter().SetTileLength(16)
+	packet := []byte("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">" + strings.Repeat(" ", 8<<10) + "</x:xmpmeta>")
+	if !opt.SetXMP(packet) {
+		t.Fatal("SetXMP failed")
+	}
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &opt); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+
+	ra := &countingReaderAt{r: bytes.NewReader(data)}
+	p, err := OpenReaderAt(ra, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if ra.bytes > 2*readerAtPageSize {
+		t.Fatalf("OpenReaderAt: %d bytes read, want the large entries to be read later", ra.bytes)
+	}
+	ifd := p.Ifd[0][0]
+	for _, tag := range []TagType{TagType_TileOffsets, TagType_TileByteCounts, TagType_XMP} {
+		if entry := ifd.EntryMap[tag]; entry == nil || entry.Data != nil {
+			t.Fatalf("%v: %v, want an entry not read yet", tag, entry)
+		}
+	}
+
+	if got, ok := ifd.XMP(); !ok || !bytes.Equal(got, packet) {
+		t.Fatalf("XMP = %d bytes, %v, want %d bytes", len(got), ok, len(packet))
+	}
+	r := image.Rect(100, 200, 140, 230)
+	m, err := p.DecodeRegion(0, 0, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, img.SubImage(r), m)
+
+	entry := ifd.EntryMap[TagType_TileOffsets]
+	if err := entry.Load(); err != nil || len(entry.Data) != 4096*4 {
+		t.Fatalf("Load: %d bytes, %v", len(entry.Data), err)
+	}
+
+	// The getters of an entry which can not be read return no value.
+	for _, dataType := range []DataType{DataType_Byte, DataType_SByte, DataType_Short, DataType_Long} {
+		entry := &IFDEntry{
+			Header:   NewHeader(false, 8),
+			DataType: dataType,
+			Count:    8 << 10,
+			lazy:     &lazyEntryData{r: bytes.NewReader(nil), size: 8 << 10 * dataType.ByteSize()},
+		}
+		if v := entry.GetInts(); v != nil {
+			t.Fatalf("%v: GetInts = %d values, want none", dataType, len(v))
+		}
+		if err := entry.Load(); err == nil {
+			t.Fatalf("%v: Load: got no error", dataType)
+		}
+	}
+}
+
+func TestReadBlocksMaxRead(t *testing.T) {
+	data := make([]byte, 3*readerAtMaxRead/2)
+	ra := &countingReaderAt{r: bytes.NewReader(data)}
+	r := newReaderAtSeeker(ra, int64(len(data)))
+
+	// Contiguous blocks are split into reads of readerAtMaxRead at most.
+	var blocks []blockRange
+	for off := 0; off < len(data); off += readerAtMaxRead / 4 {
+		blocks = append(blocks, blockRange{offset: int64(off), count: readerAtMaxRead / 4})
+	}
+	got, err := readBlocks(r, nil, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.calls != 2 || ra.bytes != len(data) {
+		t.Fatalf("readBlocks: %d reads of %d bytes, want 2 reads of %d bytes", ra.calls, ra.bytes, len(data))
+	}
+	for i, b := range got {
+		if len(b) != readerAtMaxRead/4 {
+			t.Fatalf("block %d: %d bytes, want %d", i, len(b), readerAtMaxRead/4)
+		}
+	}
+}
+
+func TestBlockCache(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "video-001-tile-64x64.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ra := &countingReaderAt{r: bytes.NewReader(data)}
+	p, err := OpenReaderAt(ra, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	p.SetBlockCacheSize(1 << 20)
+
+	// A cached block is not read again.
+	ra.calls = 0
+	b0, err := p.DecodeImageBlock(0, 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1, err := p.DecodeImageBlock(0, 0, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.calls != 1 || b0 != b1 {
+		t.Fatalf("DecodeImageBlock: %d reads, want 1 and the cached image", ra.calls)
+	}
+
+	// DecodeRegion reads the missing blocks only, and fills the cache.
+	ra.calls = 0
+	r := image.Rect(40, 10, 100, 50)
+	m, err := p.DecodeRegion(0, 0, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, want.(*image.RGBA).SubImage(r), m)
+	if ra.calls != 1 {
+		t.Fatalf("DecodeRegion: %d reads, want 1", ra.calls)
+	}
+	ra.calls = 0
+	if m, err = p.DecodeRegion(0, 0, r); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, want.(*image.RGBA).SubImage(r), m)
+	if ra.calls != 0 {
+		t.Fatalf("DecodeRegion: %d reads, want 0", ra.calls)
+	}
+	if m, err = p.DecodeImage(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	compare(t, want, m)
+
+	// The cache keeps within its budget, evicting the oldest blocks.
+	size := SizeofImage(b0)
+	p.SetBlockCacheSize(2 * size)
+	for col := 0; col < 3; col++ {
+		if _, err = p.DecodeImageBlock(0, 0, col, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.BlockCacheSize(); n != 2*size {
+		t.Fatalf("BlockCacheSize = %d, want %d", n, 2*size)
+	}
+	ra.calls = 0
+	p.DecodeImageBlock(0, 0, 2, 0)
+	p.DecodeImageBlock(0, 0, 0, 0)
+	if ra.calls != 1 {
+		t.Fatalf("DecodeImageBlock: %d reads, want 1", ra.calls)
+	}
+}
+
+func TestRows(t *testing.T) {
+	for _, filename := range []string{
+		"video-001-strip-64.tiff",
+		"video-001-tile-64x64.tiff",
+		"video-001-gray-16bit.tiff",
+		"bw-packbits.tiff",
+	} {
+		data, err := ioutil.ReadFile(testdataDir + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		m, err := p.DecodeImageWithOptions(0, 0, &DecodeOptions{KeepRawSamples: true})
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		want := m.(*MemPImage)
+
+		rows, err := p.Rows(0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		ifd := p.Ifd[0][0]
+		blockHeight := ifd.BlockBounds(0, 0).Dy()
+		n := 0
+		for rows.Next() {
+			if rows.Y() != n {
+				t.Fatalf("%s: Y = %d, want %d", filename, rows.Y(), n)
+			}
+			off := want.PixOffset(0, n)
+			if row := rows.Row(); !bytes.Equal(row, want.XPix[off:off+len(row)]) || len(row) != want.XStride {
+				t.Fatalf("%s: row %d differs", filename, n)
+			}
+			if dy := rows.band.Bounds().Dy(); dy > blockHeight {
+				t.Fatalf("%s: %d decoded rows, want at most %d", filename, dy, blockHeight)
+			}
+			n++
+		}
+		if err = rows.Err(); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if n != ifd.Bounds().Dy() {
+			t.Fatalf("%s: %d rows, want %d", filename, n, ifd.Bounds().Dy())
+		}
+	}
+
+	// The converted samples of JPEG and YCbCr images are rejected up front.
+	for _, filename := range []string{
+		"lena512color.jpeg.tiff",
+		"multipage/multipage-sample.tif",
+	} {
+		data, err := ioutil.ReadFile(testdataDir + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		if _, err := p.Rows(0, 0); err == nil {
+			t.Fatalf("%s: Rows: no error for %v, %v", filename, p.Ifd[0][0].ImageType(), p.Ifd[0][0].Compression())
+		}
+		if _, err := p.Rows(0, 1); err == nil {
+			t.Fatalf("%s: Rows: no error for a missing sub image", filename)
+		}
+	}
+}
+
 // Do not panic when image dimensions are zero, return zero-sized
 // image instead.
 // Issue golang/go#10393.
@@ -9,7 +9,9 @@ import (
 	"compress/zlib"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"image"
+	"image/draw"
 	"io"
 	"sort"
 )
@@ -18,9 +20,20 @@ import (
 // The basic structure of a TIFF file written by this package is:
 //
 //   1. Header (8 bytes).
-//   2. Image data.
-//   3. Image File Directory (IFD).
-//   4. "Pointer area" for larger entries in the IFD.
+//   2. For each image, in the order of the IFD chain:
+//      a. Its SubIFD images (overviews with OverviewsAsSubIFDs), each laid
+//         out as an image of its own.
+//      b. Image data (strips or tiles).
+//      c. The Interoperability, Exif and GPS IFDs, if any.
+//      d. Image File Directory (IFD), on a word boundary.
+//      e. "Pointer area" for larger entries in the IFD.
+//
+// The overviews written as top-level IFDs follow their image as the next
+// images of the chain.
+//
+// EncodeCOG writes a Cloud Optimized GeoTIFF instead, with all the IFDs
+// (and their Exif IFDs) right after the header and its ghost area, followed
+// by the tiles of the smallest overview first up to the full image.
 
 // We only write little-endian TIFF files.
 var enc = binary.LittleEndian
@@ -28,22 +41,34 @@ var enc = binary.LittleEndian
 // An ifdEntry is a single entry in an Image File Directory.
 // A value of type DataType_Rational is composed of two 32-bit values,
 // thus data contains two uints (numerator and denominator) for a single number.
+// Likewise a DataType_Double value is stored as its low and high 32 bits.
+// Signed and floating point values are stored as their bits.
 type ifdEntry struct {
 	tag      TagType
 	datatype DataType
 	data     []uint32
 }
 
+// count returns the number of values of e.
+func (e ifdEntry) count() int {
+	switch e.datatype {
+	case DataType_Rational, DataType_SRational, DataType_Double:
+		return len(e.data) / 2
+	}
+	return len(e.data)
+}
+
 func (e ifdEntry) putData(p []byte) {
 	for _, d := range e.data {
 		switch e.datatype {
-		case DataType_Byte, DataType_ASCII:
+		case DataType_Byte, DataType_ASCII, DataType_SByte, DataType_Undefined:
 			p[0] = byte(d)
 			p = p[1:]
-		case DataType_Short:
+		case DataType_Short, DataType_SShort:
 			enc.PutUint16(p, uint16(d))
 			p = p[2:]
-		case DataType_Long, DataType_Rational:
+		case DataType_Long, DataType_Rational, DataType_IFD,
+			DataType_SLong, DataType_SRational, DataType_Float, DataType_Double:
 			enc.PutUint32(p, uint32(d))
 			p = p[4:]
 		}
@@ -216,11 +241,28 @@ func writePix(w io.Writer, pix []byte, nrows, length, stride int) error {
 		if _, err := w.Write(pix[:length]); err != nil {
 			return err
 		}
-		pix = pix[stride:]
+		// The last row of a sub-image may end before stride.
+		if nrows > 1 {
+			pix = pix[stride:]
+		}
 	}
 	return nil
 }
 
+// writeAlignedIFD writes the IFD d at offset, after a padding byte when
+// offset is odd, as IFDs begin on a word boundary. It returns the offset of
+// the IFD, the offset of its next IFD field and the offset following it.
+func writeAlignedIFD(w EncoderWriter, offset int, d []ifdEntry) (ifdOffset, nextOffset, end int, err error) {
+	if offset%2 != 0 {
+		if _, err = w.Write([]byte{0}); err != nil {
+			return
+		}
+		offset++
+	}
+	nextOffset, end, err = writeIFD(w, offset, d)
+	return offset, nextOffset, end, err
+}
+
 func writeIFD(w EncoderWriter, ifdOffset int, d []ifdEntry) (int, int, error) {
 	const ifdLen = 12
 
@@ -245,18 +287,17 @@ func writeIFD(w EncoderWriter, ifdOffset int, d []ifdEntry) (int, int, error) {
 	for _, ent := range d {
 		enc.PutUint16(buf[0:2], uint16(ent.tag))
 		enc.PutUint16(buf[2:4], uint16(ent.datatype))
-		count := uint32(len(ent.data))
-		if ent.datatype == DataType_Rational {
-			count /= 2
-		}
+		count := uint32(ent.count())
 		enc.PutUint32(buf[4:8], count)
 		datalen := int(count * uint32(ent.datatype.ByteSize()))
 		if datalen <= 4 {
 			ent.putData(buf[8:12])
 		} else {
-			if (o + datalen) > len(parea) {
+			// The values begin on a word boundary, as the IFD.
+			padded := datalen + datalen%2
+			if (o + padded) > len(parea) {
 				newlen := len(parea) + 1024
-				for (o + datalen) > newlen {
+				for (o + padded) > newlen {
 					newlen += 1024
 				}
 				newarea := make([]byte, newlen)
@@ -265,7 +306,7 @@ func writeIFD(w EncoderWriter, ifdOffset int, d []ifdEntry) (int, int, error) {
 			}
 			ent.putData(parea[o : o+datalen])
 			enc.PutUint32(buf[8:12], uint32(pstart+o))
-			o += datalen
+			o += padded
 		}
 
 		// Length of buffer per ifd entry.
@@ -297,6 +338,448 @@ type EncoderWriter interface {
 	io.Writer
 }
 
+// An encodedImage is an image ready to be written: its IFD entries, except
+// for the strip or tile offsets and counts, and its compressed blocks.
+type encodedImage struct {
+	ifd    []ifdEntry
+	blocks [][]byte
+	tiled  bool
+
+	// subIFDs are written before the image and referenced by its SubIFD tag.
+	subIFDs []*encodedImage
+
+	// exif, interop and gps are the entries of the Exif, Interoperability
+	// and GPS IFDs, written before the image. See writeExifIFDs.
+	exif, interop, gps []ifdEntry
+}
+
+// encoderTags are the tags of Options.EntryMap which are not written as
+// they are: the encoder sets them from the image and the options, or they
+// point to other parts of the file.
+var encoderTags = map[TagType]bool{
+	TagType_NewSubfileType:              true,
+	TagType_ImageWidth:                  true,
+	TagType_ImageLength:                 true,
+	TagType_BitsPerSample:               true,
+	TagType_Compression:                 true,
+	TagType_PhotometricInterpretation:   true,
+	TagType_FillOrder:                   true,
+	TagType_StripOffsets:                true,
+	TagType_SamplesPerPixel:             true,
+	TagType_RowsPerStrip:                true,
+	TagType_StripByteCounts:             true,
+	TagType_PlanarConfiguration:         true,
+	TagType_FreeOffsets:                 true,
+	TagType_FreeByteCounts:              true,
+	TagType_Predictor:                   true,
+	TagType_ColorMap:                    true,
+	TagType_TileWidth:                   true,
+	TagType_TileLength:                  true,
+	TagType_TileOffsets:                 true,
+	TagType_TileByteCounts:              true,
+	TagType_SubIFD:                      true,
+	TagType_ExtraSamples:                true,
+	TagType_SampleFormat:                true,
+	TagType_JPEGInterchangeFormat:       true,
+	TagType_JPEGInterchangeFormatLength: true,
+	TagType_ExifIFD:                     true,
+	TagType_GPSIFD:                      true,
+	TagType_InteroperabilityIFD:         true,
+}
+
+// compressedStripSize is the default uncompressed size of the strips of a
+// compressed image, so that the strips can be compressed in parallel.
+const compressedStripSize = 256 << 10
+
+// encodeImage compresses m with the options o. The image is split into
+// tiles when o has the TileWidth and TileLength tags, or else into strips
+// of RowsPerStrip rows. By default an uncompressed image is a single
+// strip, and a compressed image has strips of about 256KB.
+//
+// The blocks are compressed by o.Workers goroutines.
+func encodeImage(m image.Image, o *Options) (e *encodedImage, err error) {
+	d := m.Bounds().Size()
+
+	compression := TagValue_CompressionType_None
+	predictor := false
+	fillOrder := TagValue_FillOrderType_MSB2LSB
+	blockWidth, blockHeight, tiled := d.X, d.Y, false
+	hasRowsPerStrip := false
+	workers := 0
+	if o != nil {
+		workers = o.Workers
+		if v, ok := o.TagGetter().GetFillOrder(); ok && TagValue_FillOrderType(v) == TagValue_FillOrderType_LSB2MSB {
+			fillOrder = TagValue_FillOrderType_LSB2MSB
+		}
+		newCompression, ok := o.TagGetter().GetCompression()
+		if ok {
+			compression = newCompression
+
+			// The predictor field is only used with LZW. See page 64 of the spec.
+			newPredictor, ok := o.TagGetter().GetPredictor()
+			if ok && newPredictor == TagValue_PredictorType_Horizontal && newCompression == TagValue_CompressionType_LZW {
+				predictor = true
+			}
+		}
+		tileWidth, ok1 := o.TagGetter().GetTileWidth()
+		tileLength, ok2 := o.TagGetter().GetTileLength()
+		if ok1 && ok2 {
+			if tileWidth <= 0 || tileLength <= 0 || tileWidth%16 != 0 || tileLength%16 != 0 {
+				err = fmt.Errorf("tiff: Encode, tile size %dx%d is not a positive multiple of 16", tileWidth, tileLength)
+				return
+			}
+			blockWidth, blockHeight, tiled = int(tileWidth), int(tileLength), true
+		} else if rowsPerStrip, ok := o.TagGetter().GetRowsPerStrip(); ok && rowsPerStrip > 0 {
+			blockHeight, hasRowsPerStrip = minInt(int(rowsPerStrip), d.Y), true
+		}
+	}
+
+	switch compression {
+	case TagValue_CompressionType_None, TagValue_CompressionType_Deflate:
+	default:
+		err = fmt.Errorf("tiff: Encode, unsupported compression %v", compression)
+		return
+	}
+
+	pr := uint32(TagValue_PredictorType_None)
+	photometricInterpretation := uint32(TagValue_PhotometricType_RGB)
+	samplesPerPixel := uint32(4)
+	bitsPerSample := []uint32{8, 8, 8, 8}
+	extraSamples := uint32(0)
+	colorMap := []uint32{}
+
+	if predictor {
+		pr = uint32(TagValue_PredictorType_Horizontal)
+	}
+	switch m := m.(type) {
+	case *image.Paletted:
+		photometricInterpretation = uint32(TagValue_PhotometricType_Paletted)
+		samplesPerPixel = 1
+		bitsPerSample = []uint32{8}
+		colorMap = make([]uint32, 256*3)
+		for i := 0; i < 256 && i < len(m.Palette); i++ {
+			r, g, b, _ := m.Palette[i].RGBA()
+			colorMap[i+0*256] = uint32(r)
+			colorMap[i+1*256] = uint32(g)
+			colorMap[i+2*256] = uint32(b)
+		}
+	case *image.Gray:
+		photometricInterpretation = uint32(TagValue_PhotometricType_BlackIsZero)
+		samplesPerPixel = 1
+		bitsPerSample = []uint32{8}
+	case *image.Gray16:
+		photometricInterpretation = uint32(TagValue_PhotometricType_BlackIsZero)
+		samplesPerPixel = 1
+		bitsPerSample = []uint32{16}
+	case *image.NRGBA:
+		extraSamples = 2 // Unassociated alpha.
+	case *image.NRGBA64:
+		extraSamples = 2 // Unassociated alpha.
+		bitsPerSample = []uint32{16, 16, 16, 16}
+	case *image.RGBA:
+		extraSamples = 1 // Associated alpha.
+	case *image.RGBA64:
+		extraSamples = 1 // Associated alpha.
+		bitsPerSample = []uint32{16, 16, 16, 16}
+	default:
+		extraSamples = 1 // Associated alpha.
+	}
+
+	if !tiled && !hasRowsPerStrip && compression != TagValue_CompressionType_None {
+		if rowSize := d.X * int(samplesPerPixel) * int(bitsPerSample[0]) / 8; rowSize > 0 {
+			blockHeight = minInt(compressedStripSize/rowSize+1, d.Y)
+		}
+	}
+
+	var rects []image.Rectangle
+	b := m.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y += blockHeight {
+		for x := b.Min.X; x < b.Max.X; x += blockWidth {
+			r := image.Rect(x, y, x+blockWidth, y+blockHeight)
+			if !tiled {
+				r = r.Intersect(b)
+			}
+			rects = append(rects, r)
+		}
+	}
+
+	// The data of each block is written into its own buffer, so that the
+	// blocks can be compressed in parallel and we know their size.
+	e = &encodedImage{tiled: tiled, blocks: make([][]byte, len(rects))}
+	err = parallelDo(len(rects), workers, func(k int) error {
+		var buf bytes.Buffer
+		if err := encodeBlock(&buf, blockImage(m, rects[k]), compression, predictor); err != nil {
+			return err
+		}
+		if fillOrder == TagValue_FillOrderType_LSB2MSB {
+			reverseBits(buf.Bytes())
+		}
+		e.blocks[k] = buf.Bytes()
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	e.ifd = []ifdEntry{
+		{TagType_ImageWidth, dimensionType(d.X), []uint32{uint32(d.X)}},
+		{TagType_ImageLength, dimensionType(d.Y), []uint32{uint32(d.Y)}},
+		{TagType_BitsPerSample, DataType_Short, bitsPerSample},
+		{TagType_Compression, DataType_Short, []uint32{uint32(compression)}},
+		{TagType_PhotometricInterpretation, DataType_Short, []uint32{photometricInterpretation}},
+		{TagType_SamplesPerPixel, DataType_Short, []uint32{samplesPerPixel}},
+	}
+	if tiled {
+		e.ifd = append(e.ifd,
+			ifdEntry{TagType_TileWidth, dimensionType(blockWidth), []uint32{uint32(blockWidth)}},
+			ifdEntry{TagType_TileLength, dimensionType(blockHeight), []uint32{uint32(blockHeight)}},
+		)
+	} else {
+		e.ifd = append(e.ifd, ifdEntry{TagType_RowsPerStrip, dimensionType(blockHeight), []uint32{uint32(blockHeight)}})
+	}
+	if fillOrder != TagValue_FillOrderType_MSB2LSB {
+		e.ifd = append(e.ifd, ifdEntry{TagType_FillOrder, DataType_Short, []uint32{uint32(fillOrder)}})
+	}
+	if pr != uint32(TagValue_PredictorType_None) {
+		e.ifd = append(e.ifd, ifdEntry{TagType_Predictor, DataType_Short, []uint32{pr}})
+	}
+	if len(colorMap) != 0 {
+		e.ifd = append(e.ifd, ifdEntry{TagType_ColorMap, DataType_Short, colorMap})
+	}
+	if extraSamples > 0 {
+		e.ifd = append(e.ifd, ifdEntry{TagType_ExtraSamples, DataType_Short, []uint32{extraSamples}})
+	}
+
+	// TIFF 6.0 baseline readers require the resolution, so give a default
+	// of 72x72 dpi unless o sets it.
+	for _, ent := range []ifdEntry{
+		{TagType_XResolution, DataType_Rational, []uint32{72, 1}},
+		{TagType_YResolution, DataType_Rational, []uint32{72, 1}},
+		{TagType_ResolutionUnit, DataType_Short, []uint32{uint32(TagValue_ResolutionUnitType_PerInch)}},
+	} {
+		if o == nil || o.EntryMap[ent.tag] == nil {
+			e.ifd = append(e.ifd, ent)
+		}
+	}
+
+	// The other entries of o, such as ImageDescription, Software or the
+	// resolution, are written as they are.
+	if o != nil {
+		for tag, entry := range o.EntryMap {
+			if encoderTags[tag] || entry == nil {
+				continue
+			}
+			var ent ifdEntry
+			if ent, err = ifdEntryOf(entry); err != nil {
+				return
+			}
+			e.ifd = append(e.ifd, ent)
+		}
+	}
+	return
+}
+
+// ifdEntryOf converts entry, as read from a file or set in Options, to an
+// ifdEntry. BigTIFF 64-bit values are converted to their 32-bit type.
+func ifdEntryOf(entry *IFDEntry) (e ifdEntry, err error) {
+	e = ifdEntry{tag: entry.Tag, datatype: entry.DataType}
+
+	size := entry.DataType.ByteSize()
+	data := entry.data()
+	if size == 0 || len(data) < entry.Count*size {
+		err = fmt.Errorf("tiff: Encode, bad entry %v", entry.Tag)
+		return
+	}
+	order := binary.ByteOrder(binary.LittleEndian)
+	if entry.Header != nil && entry.Header.ByteOrder != nil {
+		order = entry.Header.ByteOrder
+	}
+	data = data[:entry.Count*size]
+
+	switch entry.DataType {
+	case DataType_Byte, DataType_ASCII, DataType_SByte, DataType_Undefined:
+		for _, v := range data {
+			e.data = append(e.data, uint32(v))
+		}
+	case DataType_Short, DataType_SShort:
+		for i := 0; i < len(data); i += 2 {
+			e.data = append(e.data, uint32(order.Uint16(data[i:])))
+		}
+	case DataType_Long, DataType_SLong, DataType_Float, DataType_IFD,
+		DataType_Rational, DataType_SRational:
+		for i := 0; i < len(data); i += 4 {
+			e.data = append(e.data, order.Uint32(data[i:]))
+		}
+	case DataType_Double:
+		for i := 0; i < len(data); i += 8 {
+			v := order.Uint64(data[i:])
+			e.data = append(e.data, uint32(v), uint32(v>>32))
+		}
+	case DataType_Long8, DataType_IFD8:
+		e.datatype = DataType_Long
+		if entry.DataType == DataType_IFD8 {
+			e.datatype = DataType_IFD
+		}
+		for i := 0; i < len(data); i += 8 {
+			v := order.Uint64(data[i:])
+			if v > 0xffffffff {
+				err = fmt.Errorf("tiff: Encode, value %d of %v is too large for a classic TIFF", v, entry.Tag)
+				return
+			}
+			e.data = append(e.data, uint32(v))
+		}
+	case DataType_SLong8:
+		e.datatype = DataType_SLong
+		for i := 0; i < len(data); i += 8 {
+			v := int64(order.Uint64(data[i:]))
+			if v != int64(int32(v)) {
+				err = fmt.Errorf("tiff: Encode, value %d of %v is too large for a classic TIFF", v, entry.Tag)
+				return
+			}
+			e.data = append(e.data, uint32(int32(v)))
+		}
+	default:
+		err = fmt.Errorf("tiff: Encode, unsupported data type %v of %v", entry.DataType, entry.Tag)
+	}
+	return
+}
+
+// dimensionType returns the smallest data type which holds the size n.
+func dimensionType(n int) DataType {
+	if n > 0xffff {
+		return DataType_Long
+	}
+	return DataType_Short
+}
+
+// encodeBlock writes the compressed pixel data of m to w.
+func encodeBlock(w io.Writer, m image.Image, compression TagValue_CompressionType, predictor bool) (err error) {
+	// dst holds the destination for the pixel data of the image --
+	// either w or a compressor writing to w.
+	var dst = w
+	if compression == TagValue_CompressionType_Deflate {
+		dst = zlib.NewWriter(w)
+	}
+
+	d := m.Bounds().Size()
+	switch m := m.(type) {
+	case *image.Paletted:
+		err = encodeGray(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.Gray:
+		err = encodeGray(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.Gray16:
+		err = encodeGray16(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.NRGBA:
+		err = encodeRGBA(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.NRGBA64:
+		err = encodeRGBA64(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.RGBA:
+		err = encodeRGBA(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	case *image.RGBA64:
+		err = encodeRGBA64(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
+	default:
+		err = encode(dst, m, predictor)
+	}
+	if err != nil {
+		return
+	}
+
+	if c, ok := dst.(io.Closer); ok {
+		err = c.Close()
+	}
+	return
+}
+
+// blockImage returns the part r of m. The parts of r outside of m, as in
+// the tiles on the right and bottom edges, are zero.
+func blockImage(m image.Image, r image.Rectangle) image.Image {
+	type subImager interface {
+		SubImage(r image.Rectangle) image.Image
+	}
+	if r.In(m.Bounds()) {
+		if sub, ok := m.(subImager); ok {
+			return sub.SubImage(r)
+		}
+	}
+
+	var dst draw.Image
+	switch m := m.(type) {
+	case *image.Paletted:
+		// Copy the indexes, drawing would map the colors to the palette.
+		p := image.NewPaletted(r, m.Palette)
+		src := r.Intersect(m.Bounds())
+		for y := src.Min.Y; y < src.Max.Y; y++ {
+			copy(p.Pix[p.PixOffset(src.Min.X, y):p.PixOffset(src.Max.X, y)], m.Pix[m.PixOffset(src.Min.X, y):])
+		}
+		return p
+	case *image.Gray:
+		dst = image.NewGray(r)
+	case *image.Gray16:
+		dst = image.NewGray16(r)
+	case *image.NRGBA:
+		dst = image.NewNRGBA(r)
+	case *image.NRGBA64:
+		dst = image.NewNRGBA64(r)
+	case *image.RGBA64:
+		dst = image.NewRGBA64(r)
+	default:
+		dst = image.NewRGBA(r)
+	}
+	draw.Draw(dst, r, m, r.Min, draw.Src)
+	return dst
+}
+
+// ifdWithBlocks returns the IFD entries of e with the given block offsets
+// and byte counts.
+func (e *encodedImage) ifdWithBlocks(offsets, byteCounts []uint32) []ifdEntry {
+	if e.tiled {
+		return append([]ifdEntry{
+			{TagType_TileOffsets, DataType_Long, offsets},
+			{TagType_TileByteCounts, DataType_Long, byteCounts},
+		}, e.ifd...)
+	}
+	return append([]ifdEntry{
+		{TagType_StripOffsets, DataType_Long, offsets},
+		{TagType_StripByteCounts, DataType_Long, byteCounts},
+	}, e.ifd...)
+}
+
+// writeImage writes the sub IFDs, the strip data and the IFD of e at offset.
+// It returns the offset of the IFD, the offset of its next IFD field and
+// the offset following all the written data.
+func writeImage(w EncoderWriter, offset int, e *encodedImage) (ifdOffset, nextOffset, end int, err error) {
+	var subIFDs []uint32
+	for _, sub := range e.subIFDs {
+		var subOffset int
+		if subOffset, _, offset, err = writeImage(w, offset, sub); err != nil {
+			return
+		}
+		subIFDs = append(subIFDs, uint32(subOffset))
+	}
+
+	var offsets, byteCounts []uint32
+	for _, b := range e.blocks {
+		if _, err = w.Write(b); err != nil {
+			return
+		}
+		offsets = append(offsets, uint32(offset))
+		byteCounts = append(byteCounts, uint32(len(b)))
+		offset += len(b)
+	}
+
+	var pointers []ifdEntry
+	if pointers, offset, err = writeExifIFDs(w, offset, e); err != nil {
+		return
+	}
+
+	ifd := append(e.ifdWithBlocks(offsets, byteCounts), pointers...)
+	if len(subIFDs) != 0 {
+		ifd = append(ifd, ifdEntry{TagType_SubIFD, DataType_IFD, subIFDs})
+	}
+
+	return writeAlignedIFD(w, offset, ifd)
+}
+
 func EncodeAll(w EncoderWriter, images [][]image.Image, opt [][]*Options) error {
 	_, err := io.WriteString(w, ClassicTiffLittleEnding)
 	if err != nil {
@@ -329,170 +812,47 @@ func EncodeAll(w EncoderWriter, images [][]image.Image, opt [][]*Options) error
 			o = opt[i][0]
 		}
 
-		d := m.Bounds().Size()
-
-		compression := TagValue_CompressionType_None
-		predictor := false
-		if o != nil {
-			newCompression, ok := o.TagGetter().GetCompression()
-			if ok {
-				compression = newCompression
-
-				// The predictor field is only used with LZW. See page 64 of the spec.
-				newPredictor, ok := o.TagGetter().GetPredictor()
-				if ok && newPredictor == TagValue_PredictorType_Horizontal && newCompression == TagValue_CompressionType_LZW {
-					predictor = true
-				}
-			}
-		}
-
-		// Compressed data is written into a buffer first, so that we
-		// know the compressed size.
-		var buf bytes.Buffer
-		// dst holds the destination for the pixel data of the image --
-		// either w or a writer to buf.
-		var dst io.Writer
-		// imageLen is the length of the pixel data in bytes.
-		// The offset of the IFD is imageLen + 8 header bytes.
-		var imageLen int
-
-		switch compression {
-		case TagValue_CompressionType_None:
-			dst = w
-
-			switch m.(type) {
-			case *image.Paletted:
-				imageLen = d.X * d.Y * 1
-			case *image.Gray:
-				imageLen = d.X * d.Y * 1
-			case *image.Gray16:
-				imageLen = d.X * d.Y * 2
-			case *image.RGBA64:
-				imageLen = d.X * d.Y * 8
-			case *image.NRGBA64:
-				imageLen = d.X * d.Y * 8
-			default:
-				imageLen = d.X * d.Y * 4
-			}
-
-			currentOffset += imageLen
-		case TagValue_CompressionType_Deflate:
-			dst = zlib.NewWriter(&buf)
+		e, err := encodeImage(m, o)
+		if err != nil {
+			return err
 		}
-
-		pr := uint32(TagValue_PredictorType_None)
-		photometricInterpretation := uint32(TagValue_PhotometricType_RGB)
-		samplesPerPixel := uint32(4)
-		bitsPerSample := []uint32{8, 8, 8, 8}
-		extraSamples := uint32(0)
-		colorMap := []uint32{}
-
-		if predictor {
-			pr = uint32(TagValue_PredictorType_Horizontal)
-		}
-		switch m := m.(type) {
-		case *image.Paletted:
-			photometricInterpretation = uint32(TagValue_PhotometricType_Paletted)
-			samplesPerPixel = 1
-			bitsPerSample = []uint32{8}
-			colorMap = make([]uint32, 256*3)
-			for i := 0; i < 256 && i < len(m.Palette); i++ {
-				r, g, b, _ := m.Palette[i].RGBA()
-				colorMap[i+0*256] = uint32(r)
-				colorMap[i+1*256] = uint32(g)
-				colorMap[i+2*256] = uint32(b)
-			}
-			err = encodeGray(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.Gray:
-			photometricInterpretation = uint32(TagValue_PhotometricType_BlackIsZero)
-			samplesPerPixel = 1
-			bitsPerSample = []uint32{8}
-			err = encodeGray(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.Gray16:
-			photometricInterpretation = uint32(TagValue_PhotometricType_BlackIsZero)
-			samplesPerPixel = 1
-			bitsPerSample = []uint32{16}
-			err = encodeGray16(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.NRGBA:
-			extraSamples = 2 // Unassociated alpha.
-			err = encodeRGBA(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.NRGBA64:
-			extraSamples = 2 // Unassociated alpha.
-			bitsPerSample = []uint32{16, 16, 16, 16}
-			err = encodeRGBA64(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.RGBA:
-			extraSamples = 1 // Associated alpha.
-			err = encodeRGBA(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		case *image.RGBA64:
-			extraSamples = 1 // Associated alpha.
-			bitsPerSample = []uint32{16, 16, 16, 16}
-			err = encodeRGBA64(dst, m.Pix, d.X, d.Y, m.Stride, predictor)
-		default:
-			extraSamples = 1 // Associated alpha.
-			err = encode(dst, m, predictor)
+		if err = e.encodeExif(o); err != nil {
+			return err
 		}
-		if err != nil {
+		if err = e.encodeGeo(o); err != nil {
 			return err
 		}
 
-		if compression != TagValue_CompressionType_None {
-			if err = dst.(io.Closer).Close(); err != nil {
-				return err
-			}
-
-			// If compression is used, we only know the binary length after
-			// compression.
-			imageLen = buf.Len()
-			currentOffset += imageLen
-
-			// Write the compressed image to the writer.
-			_, err = buf.WriteTo(w)
+		var overviews []*encodedImage
+		for level, v := range makeOverviews(m, o) {
+			ovr, err := encodeImage(v, overviewOptions(o, level+1))
 			if err != nil {
 				return err
 			}
+			ovr.ifd = append(ovr.ifd, ifdEntry{TagType_NewSubfileType, DataType_Long, []uint32{uint32(TagValue_NewSubfileType_Reduced)}})
+			overviews = append(overviews, ovr)
 		}
-
-		ifd := []ifdEntry{
-			{TagType_ImageWidth, DataType_Short, []uint32{uint32(d.X)}},
-			{TagType_ImageLength, DataType_Short, []uint32{uint32(d.Y)}},
-			{TagType_BitsPerSample, DataType_Short, bitsPerSample},
-			{TagType_Compression, DataType_Short, []uint32{uint32(compression)}},
-			{TagType_PhotometricInterpretation, DataType_Short, []uint32{photometricInterpretation}},
-			{TagType_StripOffsets, DataType_Long, []uint32{uint32(currentOffset) - uint32(imageLen)}},
-			{TagType_SamplesPerPixel, DataType_Short, []uint32{samplesPerPixel}},
-			{TagType_RowsPerStrip, DataType_Short, []uint32{uint32(d.Y)}},
-			{TagType_StripByteCounts, DataType_Long, []uint32{uint32(imageLen)}},
-			// There is currently no support for storing the image
-			// resolution, so give a bogus value of 72x72 dpi.
-			{TagType_XResolution, DataType_Rational, []uint32{72, 1}},
-			{TagType_YResolution, DataType_Rational, []uint32{72, 1}},
-			{TagType_ResolutionUnit, DataType_Short, []uint32{uint32(TagValue_ResolutionUnitType_PerInch)}},
-		}
-		if pr != uint32(TagValue_PredictorType_None) {
-			ifd = append(ifd, ifdEntry{TagType_Predictor, DataType_Short, []uint32{pr}})
-		}
-		if len(colorMap) != 0 {
-			ifd = append(ifd, ifdEntry{TagType_ColorMap, DataType_Short, colorMap})
-		}
-		if extraSamples > 0 {
-			ifd = append(ifd, ifdEntry{TagType_ExtraSamples, DataType_Short, []uint32{extraSamples}})
+		if o != nil && o.OverviewsAsSubIFDs {
+			e.subIFDs, overviews = overviews, nil
 		}
 
-		outBuf := make([]byte, 4)
-		_, err = binary.Encode(outBuf, enc, uint32(currentOffset))
-		if err != nil {
-			return err
-		}
+		// The overviews which are not SubIFDs are chained after the image.
+		for _, e := range append([]*encodedImage{e}, overviews...) {
+			ifdOffset, nextOffset, end, err := writeImage(w, currentOffset, e)
+			if err != nil {
+				return err
+			}
 
-		// Write offset at idfPlaceholderOffset
-		_, err = w.WriteAt(outBuf, int64(idfPlaceholderOffset))
-		if err != nil {
-			return err
-		}
+			outBuf := make([]byte, 4)
+			if _, err = binary.Encode(outBuf, enc, uint32(ifdOffset)); err != nil {
+				return err
+			}
 
-		idfPlaceholderOffset, currentOffset, err = writeIFD(w, currentOffset, ifd)
-		if err != nil {
-			return err
+			// Write offset at idfPlaceholderOffset
+			if _, err = w.WriteAt(outBuf, int64(idfPlaceholderOffset)); err != nil {
+				return err
+			}
+			idfPlaceholderOffset, currentOffset = nextOffset, end
 		}
 	}
 
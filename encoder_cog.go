@@ -0,0 +1,182 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+)
+
+// The default tile size of EncodeCOG.
+const cogTileSize = 256
+
+// cogGhostArea is the GDAL structural metadata written after the header of
+// a COG. It tells readers that the IFDs come before the data, that tiles
+// are stored in row major order, and that every tile is preceded by its
+// size and followed by a copy of its last 4 bytes.
+var cogGhostArea = func() string {
+	const md = "LAYOUT=IFDS_BEFORE_DATA\n" +
+		"BLOCK_ORDER=ROW_MAJOR\n" +
+		"BLOCK_LEADER=SIZE_AS_UINT4\n" +
+		"BLOCK_TRAILER=LAST_4_BYTES_REPEATED\n" +
+		"KNOWN_INCOMPATIBLE_EDITION=NO\n" +
+		" " // Padding
+	return fmt.Sprintf("GDAL_STRUCTURAL_METADATA_SIZE=%06d bytes\n", len(md)) + md
+}()
+
+// EncodeCOG writes m to w as a Cloud Optimized GeoTIFF.
+//
+// The image is tiled, 256x256 unless opt has the TileWidth and TileLength
+// tags, and its overviews are chained after it. opt.Overviews gives the
+// number of overviews, zero creates overviews until the smallest one fits
+// in a single tile. opt.OverviewsAsSubIFDs is ignored.
+//
+// All the IFDs are written first, followed by the tiles of the smallest
+// overview up to the tiles of the full resolution image.
+func EncodeCOG(w EncoderWriter, m image.Image, opt *Options) (err error) {
+	var o Options
+	if opt != nil {
+		o = *opt
+	}
+	o.EntryMap = make(map[TagType]*IFDEntry)
+	if opt != nil {
+		for k, v := range opt.EntryMap {
+			o.EntryMap[k] = v
+		}
+	}
+
+	tileWidth, ok1 := o.TagGetter().GetTileWidth()
+	tileLength, ok2 := o.TagGetter().GetTileLength()
+	if !ok1 || !ok2 {
+		tileWidth, tileLength = cogTileSize, cogTileSize
+		for _, tag := range []TagType{TagType_TileWidth, TagType_TileLength} {
+			entry := &IFDEntry{Header: NewHeader(false, 8), Tag: tag, DataType: DataType_Long}
+			entry.SetInts(cogTileSize)
+			o.EntryMap[tag] = entry
+		}
+	}
+	if o.Overviews == 0 {
+		for dx, dy := m.Bounds().Dx(), m.Bounds().Dy(); dx > int(tileWidth) || dy > int(tileLength); o.Overviews++ {
+			dx, dy = (dx+1)/2, (dy+1)/2
+		}
+	}
+
+	// images holds the full resolution image followed by the overviews.
+	var images []*encodedImage
+	var e *encodedImage
+	if e, err = encodeImage(m, &o); err != nil {
+		return
+	}
+	images = append(images, e)
+	for i, v := range makeOverviews(m, &o) {
+		if e, err = encodeImage(v, overviewOptions(&o, i+1)); err != nil {
+			return
+		}
+		e.ifd = append(e.ifd, ifdEntry{TagType_NewSubfileType, DataType_Long, []uint32{uint32(TagValue_NewSubfileType_Reduced)}})
+		images = append(images, e)
+	}
+
+	if err = images[0].encodeExif(&o); err != nil {
+		return
+	}
+	if err = images[0].encodeGeo(&o); err != nil {
+		return
+	}
+
+	// Place the IFDs after the header and the ghost area, with the tile
+	// offsets set to zero for now, they do not change the IFD size. The
+	// Exif and GPS IFDs do not depend on the tiles, they are written into
+	// exifIFDs now and precede the IFD of their image.
+	offset := 8 + len(cogGhostArea)
+	ifdOffsets := make([]int, len(images))
+	exifIFDs := make([][]byte, len(images))
+	exifPointers := make([][]ifdEntry, len(images))
+	for i, e := range images {
+		buf := NewWriteAtBuffer(nil)
+		if exifPointers[i], offset, err = writeExifIFDs(buf, offset, e); err != nil {
+			return
+		}
+		exifIFDs[i] = buf.Bytes()
+		offset += offset % 2 // IFDs begin on a word boundary.
+		ifdOffsets[i] = offset
+		zeros := make([]uint32, len(e.blocks))
+		offset += ifdSize(append(e.ifdWithBlocks(zeros, zeros), exifPointers[i]...))
+	}
+
+	// Place the tiles, from the smallest overview up to the full image.
+	// Every tile has a 4 byte leader and a 4 byte trailer.
+	blockOffsets := make([][]uint32, len(images))
+	blockCounts := make([][]uint32, len(images))
+	for i := len(images) - 1; i >= 0; i-- {
+		for _, b := range images[i].blocks {
+			blockOffsets[i] = append(blockOffsets[i], uint32(offset+4))
+			blockCounts[i] = append(blockCounts[i], uint32(len(b)))
+			offset += 4 + len(b) + 4
+		}
+	}
+	if int64(offset) > 0xffffffff {
+		err = fmt.Errorf("tiff: EncodeCOG, the image is too large for a classic TIFF")
+		return
+	}
+
+	// Header.
+	if _, err = io.WriteString(w, ClassicTiffLittleEnding); err != nil {
+		return
+	}
+	if err = binary.Write(w, enc, uint32(ifdOffsets[0])); err != nil {
+		return
+	}
+	if _, err = io.WriteString(w, cogGhostArea); err != nil {
+		return
+	}
+	offset = 8 + len(cogGhostArea)
+
+	// IFDs.
+	for i, e := range images {
+		if _, err = w.Write(exifIFDs[i]); err != nil {
+			return
+		}
+		offset += len(exifIFDs[i])
+		var nextOffset int
+		if _, nextOffset, offset, err = writeAlignedIFD(w, offset, append(e.ifdWithBlocks(blockOffsets[i], blockCounts[i]), exifPointers[i]...)); err != nil {
+			return
+		}
+		if i+1 < len(images) {
+			var buf [4]byte
+			enc.PutUint32(buf[:], uint32(ifdOffsets[i+1]))
+			if _, err = w.WriteAt(buf[:], int64(nextOffset)); err != nil {
+				return
+			}
+		}
+	}
+
+	// Tiles.
+	for i := len(images) - 1; i >= 0; i-- {
+		for _, b := range images[i].blocks {
+			var leader, trailer [4]byte
+			enc.PutUint32(leader[:], uint32(len(b)))
+			copy(trailer[:], b[len(b)-minInt(len(b), 4):])
+			for _, v := range [][]byte{leader[:], b, trailer[:]} {
+				if _, err = w.Write(v); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+// ifdSize returns the number of bytes writeIFD writes for d.
+func ifdSize(d []ifdEntry) int {
+	n := 2 + 12*len(d) + 4
+	for _, ent := range d {
+		if datalen := ent.count() * ent.datatype.ByteSize(); datalen > 4 {
+			n += datalen + datalen%2
+		}
+	}
+	return n
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+// appendExifEntry appends entry to d with the given tag. The tags pointing
+// to other IFDs are skipped, the encoder sets them.
+func appendExifEntry(d []ifdEntry, tag TagType, entry *IFDEntry) ([]ifdEntry, error) {
+	if entry == nil || tag == TagType_InteroperabilityIFD {
+		return d, nil
+	}
+	ent, err := ifdEntryOf(entry)
+	if err != nil {
+		return d, err
+	}
+	ent.tag = tag
+	return append(d, ent), nil
+}
+
+// encodeExif sets the Exif and GPS IFDs of e from o.Exif and o.GPS.
+func (e *encodedImage) encodeExif(o *Options) (err error) {
+	if o == nil {
+		return
+	}
+	for tag, entry := range o.Exif {
+		if e.exif, err = appendExifEntry(e.exif, TagType(tag), entry); err != nil {
+			return
+		}
+	}
+	for tag, entry := range o.GPS {
+		if e.gps, err = appendExifEntry(e.gps, TagType(tag), entry); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// encodeRawExif sets the Exif, Interoperability and GPS IFDs of e from the
+// ones read with ifd.
+func (e *encodedImage) encodeRawExif(ifd *IFD) (err error) {
+	if ifd.Exif != nil {
+		for tag, entry := range ifd.Exif.EntryMap {
+			if e.exif, err = appendExifEntry(e.exif, TagType(tag), entry); err != nil {
+				return
+			}
+		}
+		for tag, entry := range ifd.Exif.Interoperability {
+			if e.interop, err = appendExifEntry(e.interop, TagType(tag), entry); err != nil {
+				return
+			}
+		}
+	}
+	if ifd.GPS != nil {
+		for tag, entry := range ifd.GPS.EntryMap {
+			if e.gps, err = appendExifEntry(e.gps, TagType(tag), entry); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// writeExifIFDs writes the Interoperability, Exif and GPS IFDs of e at
+// offset. It returns the ExifIFD and GPSIFD entries pointing to them, to be
+// added to the IFD of the image, and the offset following the written data.
+// An Interoperability IFD is only written along with an Exif IFD.
+func writeExifIFDs(w EncoderWriter, offset int, e *encodedImage) (pointers []ifdEntry, end int, err error) {
+	if len(e.exif) != 0 {
+		exif := e.exif[:len(e.exif):len(e.exif)]
+		if len(e.interop) != 0 {
+			var interopOffset int
+			if interopOffset, _, offset, err = writeAlignedIFD(w, offset, e.interop); err != nil {
+				return
+			}
+			exif = append(exif, ifdEntry{TagType_InteroperabilityIFD, DataType_IFD, []uint32{uint32(interopOffset)}})
+		}
+		var exifOffset int
+		if exifOffset, _, offset, err = writeAlignedIFD(w, offset, exif); err != nil {
+			return
+		}
+		pointers = append(pointers, ifdEntry{TagType_ExifIFD, DataType_IFD, []uint32{uint32(exifOffset)}})
+	}
+	if len(e.gps) != 0 {
+		var gpsOffset int
+		if gpsOffset, _, offset, err = writeAlignedIFD(w, offset, e.gps); err != nil {
+			return
+		}
+		pointers = append(pointers, ifdEntry{TagType_GPSIFD, DataType_IFD, []uint32{uint32(gpsOffset)}})
+	}
+	end = offset
+	return
+}
@@ -0,0 +1,42 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+// geoTags are the tags written from Options.Geo.
+var geoTags = map[TagType]bool{
+	TagType_ModelPixelScaleTag:     true,
+	TagType_ModelTiepointTag:       true,
+	TagType_ModelTransformationTag: true,
+	TagType_GeoKeyDirectoryTag:     true,
+	TagType_GeoDoubleParamsTag:     true,
+	TagType_GeoAsciiParamsTag:      true,
+}
+
+// encodeGeo sets the GeoTIFF tags of e from o.Geo. They replace those of
+// o.EntryMap.
+func (e *encodedImage) encodeGeo(o *Options) (err error) {
+	if o == nil || o.Geo == nil {
+		return
+	}
+	entryMap, err := o.Geo.entryMap()
+	if err != nil {
+		return
+	}
+	ifd := e.ifd[:0]
+	for _, ent := range e.ifd {
+		if !geoTags[ent.tag] {
+			ifd = append(ifd, ent)
+		}
+	}
+	e.ifd = ifd
+	for _, entry := range entryMap {
+		var ent ifdEntry
+		if ent, err = ifdEntryOf(entry); err != nil {
+			return
+		}
+		e.ifd = append(e.ifd, ent)
+	}
+	return
+}
@@ -0,0 +1,178 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// A RawImage is an image whose strips or tiles are already compressed, as
+// returned by Reader.ReadRawImage and Reader.ReadRawPage.
+type RawImage struct {
+	// Ifd holds the tags of the image: its size, compression, layout and
+	// so on. The strip and tile offsets and byte counts are ignored, and
+	// the tags pointing to other parts of the file (SubIFD, FreeOffsets,
+	// JPEGInterchangeFormat) are not written. The Exif and GPS IFDs read
+	// with the image, Ifd.Exif and Ifd.GPS, are written again. Old-style
+	// JPEG images, whose tables are stored apart from the blocks, are not
+	// supported.
+	Ifd *IFD
+
+	// Blocks holds the compressed strips or tiles in the order of
+	// IFD.BlockOffset: row by row, and left to right in a row.
+	Blocks [][]byte
+
+	// SubIFDs are written as the SubIFDs of the image.
+	SubIFDs []*RawImage
+}
+
+// rawImageSkippedTags are the tags of RawImage.Ifd which are not written.
+var rawImageSkippedTags = map[TagType]bool{
+	TagType_StripOffsets:                true,
+	TagType_StripByteCounts:             true,
+	TagType_TileOffsets:                 true,
+	TagType_TileByteCounts:              true,
+	TagType_SubIFD:                      true,
+	TagType_ExifIFD:                     true,
+	TagType_GPSIFD:                      true,
+	TagType_InteroperabilityIFD:         true,
+	TagType_FreeOffsets:                 true,
+	TagType_FreeByteCounts:              true,
+	TagType_JPEGInterchangeFormat:       true,
+	TagType_JPEGInterchangeFormatLength: true,
+}
+
+// encodeRawImage returns the encodedImage writing the blocks of m as they are.
+func encodeRawImage(m *RawImage) (e *encodedImage, err error) {
+	if m == nil || m.Ifd == nil {
+		err = fmt.Errorf("tiff: EncodeRaw, missing IFD")
+		return
+	}
+	if n := m.Ifd.BlocksAcross() * m.Ifd.BlocksDown(); n == 0 || n != len(m.Blocks) {
+		err = fmt.Errorf("tiff: EncodeRaw, %d blocks, want %d", len(m.Blocks), n)
+		return
+	}
+	// The old-style JPEG tables and interchange format are referenced by
+	// offsets which would dangle in the written file.
+	if m.Ifd.Compression() == TagValue_CompressionType_JPEGOld {
+		err = fmt.Errorf("tiff: EncodeRaw, cannot copy old-style JPEG data")
+		return
+	}
+	// The samples are stored in the byte order of the file, which is
+	// little endian for the written file. Only JPEG data does not depend
+	// on it.
+	if h := m.Ifd.Header; h != nil && h.ByteOrder == binary.BigEndian && m.Ifd.Depth() > 8 {
+		if m.Ifd.Compression() != TagValue_CompressionType_JPEG {
+			err = fmt.Errorf("tiff: EncodeRaw, cannot copy the %d-bit samples of a big endian file", m.Ifd.Depth())
+			return
+		}
+	}
+	_, tiled := m.Ifd.TagGetter().GetTileWidth()
+
+	e = &encodedImage{tiled: tiled, blocks: m.Blocks}
+	for tag, entry := range m.Ifd.EntryMap {
+		if rawImageSkippedTags[tag] {
+			continue
+		}
+		var ent ifdEntry
+		if ent, err = ifdEntryOf(entry); err != nil {
+			return
+		}
+		e.ifd = append(e.ifd, ent)
+	}
+	if err = e.encodeRawExif(m.Ifd); err != nil {
+		return
+	}
+	for _, sub := range m.SubIFDs {
+		var subImage *encodedImage
+		if subImage, err = encodeRawImage(sub); err != nil {
+			return
+		}
+		e.subIFDs = append(e.subIFDs, subImage)
+	}
+	return
+}
+
+// EncodeRaw writes the images, whose blocks are already compressed, to w
+// as the pages of a little endian classic TIFF. The blocks are copied as
+// they are, without being decoded.
+func EncodeRaw(w EncoderWriter, images []*RawImage) (err error) {
+	if _, err = io.WriteString(w, ClassicTiffLittleEnding); err != nil {
+		return
+	}
+	if err = binary.Write(w, enc, uint32(0)); err != nil {
+		return
+	}
+
+	ifdPlaceholderOffset, offset := 4, 8
+	for _, m := range images {
+		var e *encodedImage
+		if e, err = encodeRawImage(m); err != nil {
+			return
+		}
+		var ifdOffset, nextOffset int
+		if ifdOffset, nextOffset, offset, err = writeImage(w, offset, e); err != nil {
+			return
+		}
+		if int64(offset) > 0xffffffff {
+			err = fmt.Errorf("tiff: EncodeRaw, the images are too large for a classic TIFF")
+			return
+		}
+		var buf [4]byte
+		enc.PutUint32(buf[:], uint32(ifdOffset))
+		if _, err = w.WriteAt(buf[:], int64(ifdPlaceholderOffset)); err != nil {
+			return
+		}
+		ifdPlaceholderOffset = nextOffset
+	}
+	return
+}
+
+// Split returns every page of the TIFF file r, with its SubIFDs, as a TIFF
+// file of its own. The strips and tiles are copied without being decoded.
+func Split(r io.Reader) (pages [][]byte, err error) {
+	p, err := OpenReader(r)
+	if err != nil {
+		return
+	}
+	defer p.Close()
+
+	for i := 0; i < p.ImageNum(); i++ {
+		var m *RawImage
+		if m, err = p.ReadRawPage(i); err != nil {
+			return
+		}
+		w := NewWriteAtBuffer(nil)
+		if err = EncodeRaw(w, []*RawImage{m}); err != nil {
+			return
+		}
+		pages = append(pages, w.Bytes())
+	}
+	return
+}
+
+// Merge writes the pages of the TIFF files to w, in order, as a single
+// TIFF file. The strips and tiles are copied without being decoded.
+func Merge(w EncoderWriter, files ...io.Reader) (err error) {
+	var pages []*RawImage
+	for _, r := range files {
+		var p *Reader
+		if p, err = OpenReader(r); err != nil {
+			return
+		}
+		for i := 0; i < p.ImageNum(); i++ {
+			var m *RawImage
+			if m, err = p.ReadRawPage(i); err != nil {
+				p.Close()
+				return
+			}
+			pages = append(pages, m)
+		}
+		p.Close()
+	}
+	return EncodeRaw(w, pages)
+}
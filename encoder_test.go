@@ -7,8 +7,13 @@ package tiff
 import (
 	"bytes"
 	"image"
+	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 )
 
 var roundtripTests = []struct {
@@ -91,6 +96,858 @@ func TestRoundtrip2(t *testing.T) {
 	compare(t, m0, m1)
 }
 
+// TestRoundtripFillOrder tests that FillOrder=2 reverses the bits of the
+// written strip data and that the decoder undoes it.
+func TestRoundtripFillOrder(t *testing.T) {
+	img, err := openImage("video-001-gray.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msb := NewWriteAtBuffer([]byte{})
+	if err = Encode(msb, img, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fillOrder := &IFDEntry{
+		Header:   NewHeader(false, 8),
+		Tag:      TagType_FillOrder,
+		DataType: DataType_Short,
+	}
+	fillOrder.SetInts(int64(TagValue_FillOrderType_LSB2MSB))
+	opt := &Options{EntryMap: map[TagType]*IFDEntry{
+		TagType_FillOrder: fillOrder,
+	}}
+	lsb := NewWriteAtBuffer([]byte{})
+	if err = Encode(lsb, img, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pixel data directly follows the 8 byte header.
+	n := img.Bounds().Dx() * img.Bounds().Dy()
+	want := append([]byte(nil), msb.Bytes()[8:8+n]...)
+	reverseBits(want)
+	if !bytes.Equal(lsb.Bytes()[8:8+n], want) {
+		t.Fatal("pixel data is not bit reversed")
+	}
+
+	img2, err := Decode(bytes.NewReader(lsb.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, img, img2)
+}
+
+// TestEncodeOverviews tests that the overviews are written as reduced
+// resolution images, chained or as SubIFDs, and found by Reader.Levels.
+func TestEncodeOverviews(t *testing.T) {
+	img, err := openImage("video-001.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filter := range []ResampleFilter{ResampleFilter_Nearest, ResampleFilter_Average, ResampleFilter_Lanczos} {
+		for _, asSubIFDs := range []bool{false, true} {
+			out := NewWriteAtBuffer([]byte{})
+			opt := &Options{Overviews: 3, OverviewFilter: filter, OverviewsAsSubIFDs: asSubIFDs}
+			if err = Encode(out, img, opt); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := OpenReader(bytes.NewReader(out.Bytes()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if asSubIFDs && p.ImageNum() != 1 || !asSubIFDs && p.ImageNum() != 4 {
+				t.Fatalf("%v, %v: got %d images", filter, asSubIFDs, p.ImageNum())
+			}
+			levels, err := p.Levels(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(levels) != 4 {
+				t.Fatalf("%v, %v: got %d levels, want 4", filter, asSubIFDs, len(levels))
+			}
+
+			w, h := img.Bounds().Dx(), img.Bounds().Dy()
+			for _, level := range levels {
+				if level.Width != w || level.Height != h {
+					t.Fatalf("%v, %v: level size = %dx%d, want %dx%d", filter, asSubIFDs, level.Width, level.Height, w, h)
+				}
+				m, err := p.DecodeImage(level.I, level.J)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if filter == ResampleFilter_Nearest {
+					scale := int(level.ScaleX + 0.5)
+					for y := 0; y < h; y++ {
+						for x := 0; x < w; x++ {
+							if c0, c1 := img.At(x*scale, y*scale), m.At(x, y); c0 != c1 {
+								t.Fatalf("%v, %v: pixel at (%d, %d) = %v, want %v", filter, asSubIFDs, x, y, c1, c0)
+							}
+						}
+					}
+				}
+				w, h = (w+1)/2, (h+1)/2
+			}
+			p.Close()
+		}
+	}
+}
+
+// TestEncodeCOG tests the layout of a Cloud Optimized GeoTIFF: the ghost
+// area follows the header, the IFDs come before the tiles, and the tiles of
+// the overview come before the ones of the full resolution image.
+func TestEncodeCOG(t *testing.T) {
+	img, err := openImage("video-001.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := &Options{EntryMap: map[TagType]*IFDEntry{}, OverviewFilter: ResampleFilter_Average}
+	for _, tag := range []TagType{TagType_TileWidth, TagType_TileLength} {
+		entry := &IFDEntry{Header: NewHeader(false, 8), Tag: tag, DataType: DataType_Short}
+		entry.SetInts(64)
+		opt.EntryMap[tag] = entry
+	}
+	compression := &IFDEntry{Header: NewHeader(false, 8), Tag: TagType_Compression, DataType: DataType_Short}
+	compression.SetInts(int64(TagValue_CompressionType_Deflate))
+	opt.EntryMap[TagType_Compression] = compression
+
+	out := NewWriteAtBuffer([]byte{})
+	if err = EncodeCOG(out, img, opt); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	if !bytes.HasPrefix(data[8:], []byte("GDAL_STRUCTURAL_METADATA_SIZE=000140 bytes\nLAYOUT=IFDS_BEFORE_DATA\n")) {
+		t.Fatalf("missing ghost area: %q", data[8:60])
+	}
+
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// 150x103 needs two overviews to fit in a 64x64 tile.
+	levels, err := p.Levels(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 3 || levels[2].Width != 38 || levels[2].Height != 26 {
+		t.Fatalf("levels = %v", levels)
+	}
+
+	var lastIFD int64
+	for i := 0; i < p.ImageNum(); i++ {
+		if p.Ifd[i][0].ThisIFD%2 != 0 || p.Ifd[i][0].ThisIFD < lastIFD {
+			t.Fatalf("bad IFD offset %d", p.Ifd[i][0].ThisIFD)
+		}
+		lastIFD = p.Ifd[i][0].ThisIFD
+	}
+
+	// The tiles of each level come before the tiles of the larger levels.
+	firstTile := int64(len(data))
+	for i := p.ImageNum() - 1; i >= 0; i-- {
+		ifd := p.Ifd[i][0]
+		for col := 0; col < ifd.BlocksAcross(); col++ {
+			for row := 0; row < ifd.BlocksDown(); row++ {
+				if v := ifd.BlockOffset(col, row); v <= lastIFD || v < firstTile && i != p.ImageNum()-1 {
+					t.Fatalf("image %d: tile %d/%d at %d is misplaced", i, col, row, v)
+				}
+			}
+		}
+		firstTile = ifd.BlockOffset(0, 0)
+	}
+
+	// Check the leader and trailer of every tile.
+	for i := 0; i < p.ImageNum(); i++ {
+		ifd := p.Ifd[i][0]
+		for col := 0; col < ifd.BlocksAcross(); col++ {
+			for row := 0; row < ifd.BlocksDown(); row++ {
+				off, n := ifd.BlockOffset(col, row), ifd.BlockCount(col, row)
+				if v := int64(enc.Uint32(data[off-4:])); v != n {
+					t.Fatalf("tile %d/%d: leader = %d, want %d", col, row, v, n)
+				}
+				if !bytes.Equal(data[off+n-4:off+n], data[off+n:off+n+4]) {
+					t.Fatalf("tile %d/%d: bad trailer", col, row)
+				}
+			}
+		}
+	}
+
+	m, err := p.DecodeImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, img, m)
+}
+
+// TestEncodeTiles tests uncompressed tiles on the last rows of the image,
+// whose pixels end before the stride of the image.
+func TestEncodeTiles(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 96, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i * 7)
+	}
+	var opt Options
+	opt.TagSetter().SetTileWidth(32)
+	opt.TagSetter().SetTileLength(32)
+
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &opt); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Decode(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, img, m)
+}
+
+// TestEncodeWorkers tests that a compressed image is split into strips,
+// and that the output does not depend on the number of workers.
+func TestEncodeWorkers(t *testing.T) {
+	m0 := image.NewRGBA(image.Rect(0, 0, 600, 500))
+	for i := range m0.Pix {
+		m0.Pix[i] = uint8(i * 7 / 5)
+	}
+	compression := &IFDEntry{Header: NewHeader(false, 8), Tag: TagType_Compression, DataType: DataType_Short}
+	compression.SetInts(int64(TagValue_CompressionType_Deflate))
+
+	var want []byte
+	for _, workers := range []int{1, 3, 16} {
+		opt := &Options{EntryMap: map[TagType]*IFDEntry{TagType_Compression: compression}, Workers: workers}
+		out := NewWriteAtBuffer([]byte{})
+		if err := Encode(out, m0, opt); err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = out.Bytes()
+		} else if !bytes.Equal(out.Bytes(), want) {
+			t.Fatalf("workers = %d: output differs", workers)
+		}
+	}
+
+	p, err := OpenReader(bytes.NewReader(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if n := p.ImageBlocksDown(0, 0); n != 5 {
+		t.Fatalf("got %d strips, want 5", n)
+	}
+	m1, err := p.DecodeImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, m0, m1)
+}
+
+func TestHalveImage(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 5, 3))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i * 10)
+	}
+	want := map[ResampleFilter][]uint8{
+		ResampleFilter_Nearest: {0, 20, 40, 100, 120, 140},
+		ResampleFilter_Average: {30, 50, 65, 105, 125, 140},
+	}
+	for filter, pix := range want {
+		m1 := halveImage(m, filter).(*image.Gray)
+		if m1.Bounds() != image.Rect(0, 0, 3, 2) || !bytes.Equal(m1.Pix, pix) {
+			t.Fatalf("%v: got %v %v, want %v", filter, m1.Bounds(), m1.Pix, pix)
+		}
+	}
+
+	// Lanczos keeps a constant image constant.
+	for i := range m.Pix {
+		m.Pix[i] = 77
+	}
+	m1 := halveImage(m, ResampleFilter_Lanczos).(*image.Gray)
+	for _, v := range m1.Pix {
+		if v != 77 {
+			t.Fatalf("lanczos: got %v, want all 77", m1.Pix)
+		}
+	}
+}
+
+func TestEncodeRaw(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "lena512color.jpeg.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	block, ifd, err := p.ReadRawBlock(0, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(testdataDir + "lena512color.jpeg.tiff.block00.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ifd != p.Ifd[0][0] || !bytes.Equal(block, want) {
+		t.Fatalf("ReadRawBlock: got %d bytes, want the %d bytes of block00.dat", len(block), len(want))
+	}
+	if _, _, err := p.ReadRawBlock(1, 0, 0, 0); err == nil {
+		t.Fatal("ReadRawBlock: no error for a missing image")
+	}
+	if _, err := p.ReadRawImage(0, 1); err == nil {
+		t.Fatal("ReadRawImage: no error for a missing sub image")
+	}
+	if _, err := p.ReadRawPage(-1); err == nil {
+		t.Fatal("ReadRawPage: no error for a missing image")
+	}
+
+	// The old-style JPEG tables would not be copied.
+	raw, err := p.ReadRawImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw.Ifd = &IFD{Header: ifd.Header, EntryMap: make(map[TagType]*IFDEntry)}
+	for tag, entry := range ifd.EntryMap {
+		raw.Ifd.EntryMap[tag] = entry
+	}
+	raw.Ifd.TagSetter().SetCompression(TagValue_CompressionType_JPEGOld)
+	if err := EncodeRaw(NewWriteAtBuffer(nil), []*RawImage{raw}); err == nil {
+		t.Fatal("EncodeRaw: no error for old-style JPEG data")
+	}
+
+	for _, filename := range []string{
+		"lena512color.jpeg.tiff",
+		"video-001-tile-64x64.tiff",
+		"video-001-uncompressed.tiff", // Big endian.
+		"BigTIFFSamples/BigTIFFLong8Tiles.tif",
+	} {
+		data, err := ioutil.ReadFile(testdataDir + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := p.ReadRawImage(0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		out := NewWriteAtBuffer([]byte{})
+		if err = EncodeRaw(out, []*RawImage{raw}); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+
+		q, err := OpenReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		for col := 0; col < q.ImageBlocksAcross(0, 0); col++ {
+			b0, _, err := p.ReadRawBlock(0, 0, col, 0)
+			if err != nil {
+				t.Fatalf("%s: %v", filename, err)
+			}
+			b1, _, err := q.ReadRawBlock(0, 0, col, 0)
+			if err != nil || !bytes.Equal(b0, b1) {
+				t.Fatalf("%s: block %d differs, %v", filename, col, err)
+			}
+		}
+		for tag, entry := range p.Ifd[0][0].EntryMap {
+			if entry.DataType == DataType_Double && !reflect.DeepEqual(entry.GetFloats(), q.Ifd[0][0].EntryMap[tag].GetFloats()) {
+				t.Fatalf("%s: tag %v differs", filename, tag)
+			}
+		}
+		m0, err := p.DecodeImage(0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		m1, err := q.DecodeImage(0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		compare(t, m0, m1)
+		p.Close()
+		q.Close()
+	}
+}
+
+func TestSplitMerge(t *testing.T) {
+	for _, filename := range []string{
+		"multipage/multipage-gopher.tif",
+		"compress/compress_type_g4.tif",
+		"BigTIFFSamples/BigTIFFSubIFD4.tif",
+	} {
+		data, err := ioutil.ReadFile(testdataDir + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+
+		pages, err := Split(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		if len(pages) != p.ImageNum() {
+			t.Fatalf("%s: Split returned %d pages, want %d", filename, len(pages), p.ImageNum())
+		}
+
+		var files []io.Reader
+		for _, page := range pages {
+			files = append(files, bytes.NewReader(page))
+		}
+		out := NewWriteAtBuffer(nil)
+		if err = Merge(out, files...); err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		q, err := OpenReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", filename, err)
+		}
+		defer q.Close()
+
+		if q.ImageNum() != p.ImageNum() {
+			t.Fatalf("%s: Merge wrote %d pages, want %d", filename, q.ImageNum(), p.ImageNum())
+		}
+		for i := 0; i < p.ImageNum(); i++ {
+			if q.SubImageNum(i) != p.SubImageNum(i) {
+				t.Fatalf("%s: page %d has %d sub images, want %d", filename, i, q.SubImageNum(i), p.SubImageNum(i))
+			}
+			for j := 0; j < p.SubImageNum(i); j++ {
+				if q.Ifd[i][j].Compression() != p.Ifd[i][j].Compression() {
+					t.Fatalf("%s: image %d/%d has compression %v, want %v", filename, i, j, q.Ifd[i][j].Compression(), p.Ifd[i][j].Compression())
+				}
+				b0, _, err := p.ReadRawBlock(i, j, 0, 0)
+				if err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				b1, _, err := q.ReadRawBlock(i, j, 0, 0)
+				if err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				if !bytes.Equal(b0, b1) {
+					t.Fatalf("%s: the first block of image %d/%d differs", filename, i, j)
+				}
+				m0, err := p.DecodeImage(i, j)
+				if err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				m1, err := q.DecodeImage(i, j)
+				if err != nil {
+					t.Fatalf("%s: %v", filename, err)
+				}
+				compare(t, m0, m1)
+			}
+		}
+	}
+}
+
+func TestEncodeEntryMap(t *testing.T) {
+	img, err := openImage("video-001.tiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opt Options
+	setter := opt.TagSetter()
+	texts := map[TagType]string{
+		TagType_ImageDescription: "a video frame",
+		TagType_Software:         "abcd",
+		TagType_Artist:           "gopher",
+		TagType_Copyright:        "public domain",
+		TagType_PageName:         "page 1",
+		TagType_DocumentName:     "video-001",
+	}
+	for tag, v := range texts {
+		setter.SetUnknown(tag, v)
+	}
+	date := time.Date(2015, 1, 2, 3, 4, 5, 0, time.UTC)
+	setter.SetDateTime(date)
+	setter.SetUnknown(TagType(65000), []byte{1, 2, 3, 4, 5})
+	// Structural tags are set by the encoder.
+	setter.SetImageWidth(10)
+	setter.SetBitsPerSample([]int64{1})
+
+	out := NewWriteAtBuffer(nil)
+	if err = Encode(out, img, &opt); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+
+	// The values out of the IFD begin on a word boundary, whatever the
+	// length of the previous ones, such as the odd one of Software.
+	ifdOffset := int(enc.Uint32(data[4:8]))
+	for i := 0; i < int(enc.Uint16(data[ifdOffset:])); i++ {
+		entry := data[ifdOffset+2+12*i:]
+		datalen := int(enc.Uint32(entry[4:8])) * DataType(enc.Uint16(entry[2:4])).ByteSize()
+		if offset := enc.Uint32(entry[8:12]); datalen > 4 && offset%2 != 0 {
+			t.Fatalf("tag %v is at the odd offset %d", TagType(enc.Uint16(entry[0:2])), offset)
+		}
+	}
+
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ifd := p.Ifd[0][0]
+	for tag, want := range texts {
+		if got := ifd.EntryMap[tag]; got == nil || got.GetString() != want {
+			t.Fatalf("tag %v = %v, want %q", tag, got, want)
+		}
+	}
+	if got, ok := ifd.TagGetter().GetDateTime(); !ok || !got.Equal(date) {
+		t.Fatalf("DateTime = %v, want %v", got, date)
+	}
+	if got, ok := ifd.TagGetter().GetUnknown(TagType(65000)); !ok || !bytes.Equal(got, []byte{1, 2, 3, 4, 5}) {
+		t.Fatalf("private tag = %v", got)
+	}
+	if r, _ := ifd.TagGetter().GetXResolution(); r != [2]int64{72, 1} {
+		t.Fatalf("XResolution = %v, want the default 72 dpi", r)
+	}
+	m, err := p.DecodeImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, img, m)
+}
+
+func TestEncodeResolution(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for _, tt := range []struct {
+		x, y     float64
+		unit     TagValue_ResolutionUnitType
+		dpiX     float64
+		dpiY     float64
+		rational [2]int64
+	}{
+		{300, 150, TagValue_ResolutionUnitType_PerInch, 300, 150, [2]int64{300, 1}},
+		{118.11, 118.11, TagValue_ResolutionUnitType_PerCM, 299.9994, 299.9994, [2]int64{11811, 100}},
+		{72.5, 72.5, TagValue_ResolutionUnitType_PerInch, 72.5, 72.5, [2]int64{145, 2}},
+	} {
+		var opt Options
+		if !opt.SetResolution(tt.x, tt.y, tt.unit) {
+			t.Fatalf("SetResolution(%v, %v, %v) failed", tt.x, tt.y, tt.unit)
+		}
+		out := NewWriteAtBuffer(nil)
+		if err := Encode(out, img, &opt); err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ifd := p.Ifd[0][0]
+		if r, _ := ifd.TagGetter().GetXResolution(); r != tt.rational {
+			t.Fatalf("XResolution = %v, want %v", r, tt.rational)
+		}
+		x, y, unit, ok := ifd.Resolution()
+		if !ok || x != tt.x || y != tt.y || unit != tt.unit {
+			t.Fatalf("Resolution = %v, %v, %v, %v", x, y, unit, ok)
+		}
+		x, y, ok = ifd.DPI()
+		if !ok || math.Abs(x-tt.dpiX) > 1e-9 || math.Abs(y-tt.dpiY) > 1e-9 {
+			t.Fatalf("DPI = %v, %v, %v, want %v, %v", x, y, ok, tt.dpiX, tt.dpiY)
+		}
+		p.Close()
+	}
+
+	// The resolution is 72 dpi by default.
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if x, y, ok := p.Ifd[0][0].DPI(); !ok || x != 72 || y != 72 {
+		t.Fatalf("DPI = %v, %v, %v, want the default 72 dpi", x, y, ok)
+	}
+
+	// A missing ResolutionUnit is PerInch.
+	ifd := &IFD{Header: NewHeader(false, 8), EntryMap: make(map[TagType]*IFDEntry)}
+	setter := ifd.TagSetter()
+	setter.SetXResolution([2]int64{300, 1})
+	setter.SetYResolution([2]int64{150, 1})
+	if x, y, unit, ok := ifd.Resolution(); !ok || x != 300 || y != 150 || unit != TagValue_ResolutionUnitType_PerInch {
+		t.Fatalf("Resolution = %v, %v, %v, %v", x, y, unit, ok)
+	}
+
+	// The resolution of a decoded image is carried over.
+	var from Options
+	if !from.SetResolutionFrom(ifd) {
+		t.Fatal("SetResolutionFrom failed")
+	}
+	out = NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &from); err != nil {
+		t.Fatal(err)
+	}
+	q, err := OpenReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	if x, y, ok := q.Ifd[0][0].DPI(); !ok || x != 300 || y != 150 {
+		t.Fatalf("DPI = %v, %v, %v, want 300, 150", x, y, ok)
+	}
+	if new(Options).SetResolutionFrom(&IFD{}) {
+		t.Fatal("SetResolutionFrom succeeded without a resolution")
+	}
+	badUnit := &IFDEntry{Header: NewHeader(false, 8), Tag: TagType_ResolutionUnit, DataType: DataType_Short}
+	badUnit.SetInts(2, 2)
+	ifd.EntryMap[TagType_ResolutionUnit] = badUnit
+	if new(Options).SetResolutionFrom(ifd) {
+		t.Fatal("SetResolutionFrom succeeded with a malformed ResolutionUnit")
+	}
+
+	var opt Options
+	for _, v := range []float64{0, -72, math.NaN(), 1e10} {
+		if opt.SetDPI(v, v) {
+			t.Errorf("SetDPI(%v) succeeded", v)
+		}
+	}
+}
+
+func TestEncodeIFDAlignment(t *testing.T) {
+	// 9 bytes of pixels leave the IFD on an odd offset without padding.
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, image.NewGray(image.Rect(0, 0, 3, 3)), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := out.Bytes()
+	if offset := uint32(data[4]) | uint32(data[5])<<8 | uint32(data[6])<<16 | uint32(data[7])<<24; offset%2 != 0 {
+		t.Fatalf("the IFD is at the odd offset %d", offset)
+	}
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	m, err := p.DecodeImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compare(t, image.NewGray(image.Rect(0, 0, 3, 3)), m)
+}
+
+func TestEncodeExif(t *testing.T) {
+	newEntry := func(tag TagType, dataType DataType) *IFDEntry {
+		return &IFDEntry{Header: NewHeader(false, 8), Tag: tag, DataType: dataType}
+	}
+	var opt Options
+	opt.Exif = make(map[ExifIFD_TagType]*IFDEntry)
+	opt.GPS = make(map[GPSIFD_TagType]*IFDEntry)
+	opt.Exif[ExifIFD_TagType_DateTimeOriginal] = newEntry(TagType(ExifIFD_TagType_DateTimeOriginal), DataType_ASCII)
+	opt.Exif[ExifIFD_TagType_DateTimeOriginal].SetString("2014:05:06 07:08:09")
+	opt.Exif[ExifIFD_TagType_ExposureTime] = newEntry(TagType(ExifIFD_TagType_ExposureTime), DataType_Rational)
+	opt.Exif[ExifIFD_TagType_ExposureTime].SetRationals([2]int64{1, 250})
+	opt.GPS[GPSIFD_TagType_GPSLatitudeRef] = newEntry(TagType(GPSIFD_TagType_GPSLatitudeRef), DataType_ASCII)
+	opt.GPS[GPSIFD_TagType_GPSLatitudeRef].SetString("S")
+	opt.GPS[GPSIFD_TagType_GPSLatitude] = newEntry(TagType(GPSIFD_TagType_GPSLatitude), DataType_Rational)
+	opt.GPS[GPSIFD_TagType_GPSLatitude].SetRationals([2]int64{33, 1}, [2]int64{51, 1}, [2]int64{36, 1})
+	opt.Overviews = 1
+
+	check := func(name string, data []byte) {
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer p.Close()
+		ifd := p.Ifd[0][0]
+		want := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC)
+		if v, ok := ifd.Exif.DateTimeOriginal(); !ok || !v.Equal(want) {
+			t.Fatalf("%s: DateTimeOriginal = %v, %v, want %v", name, v, ok, want)
+		}
+		if v, ok := ifd.Exif.ExposureTime(); !ok || v != 1.0/250 {
+			t.Fatalf("%s: ExposureTime = %v, %v", name, v, ok)
+		}
+		if v, ok := ifd.GPS.Latitude(); !ok || math.Abs(v+33.86) > 1e-9 {
+			t.Fatalf("%s: Latitude = %v, %v, want -33.86", name, v, ok)
+		}
+		if ovr := p.Ifd[1][0]; ovr.Exif != nil || ovr.GPS != nil {
+			t.Fatalf("%s: the overview has Exif or GPS IFDs", name)
+		}
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 300, 300))
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &opt); err != nil {
+		t.Fatal(err)
+	}
+	check("Encode", out.Bytes())
+
+	out = NewWriteAtBuffer(nil)
+	if err := EncodeCOG(out, img, &opt); err != nil {
+		t.Fatal(err)
+	}
+	check("EncodeCOG", out.Bytes())
+
+	// The Exif and GPS IFDs are copied by Split.
+	data, err := ioutil.ReadFile(testdataDir + "gdal_autotest/gcore/data/exif_and_gps.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := Split(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(pages[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ifd := p.Ifd[0][0]
+	if v, ok := ifd.Exif.GetString(ExifIFD_TagType_SpectralSensitivity); !ok || v != "EXIF Spectral Sensitivity" {
+		t.Fatalf("Split: SpectralSensitivity = %q, %v", v, ok)
+	}
+	if v, ok := ifd.GPS.Longitude(); !ok || math.Abs(v-34.2) > 1e-9 {
+		t.Fatalf("Split: Longitude = %v, %v, want 34.2", v, ok)
+	}
+}
+
+func TestEncodeGeoInfo(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "geotiff/gdal_eg/cea.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	src := p.Ifd[0][0]
+	info, err := src.GeoInfo()
+	if err != nil || info == nil {
+		t.Fatalf("GeoInfo = %v, %v", info, err)
+	}
+	wantTransform, _ := src.GeoTransform()
+
+	check := func(name string, data []byte, info *GeoInfo, transform GeoTransform) {
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		defer p.Close()
+		ifd := p.Ifd[0][0]
+		got, err := ifd.GeoInfo()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, info) {
+			t.Fatalf("%s: GeoInfo = %+v, want %+v", name, got, info)
+		}
+		if gt, ok := ifd.GeoTransform(); !ok || gt != transform {
+			t.Fatalf("%s: GeoTransform = %v, %v, want %v", name, gt, ok, transform)
+		}
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 300, 300))
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &Options{Geo: info}); err != nil {
+		t.Fatal(err)
+	}
+	check("Encode", out.Bytes(), info, wantTransform)
+
+	out = NewWriteAtBuffer(nil)
+	if err := EncodeCOG(out, img, &Options{Geo: info}); err != nil {
+		t.Fatal(err)
+	}
+	check("EncodeCOG", out.Bytes(), info, wantTransform)
+
+	// A new rotated PixelIsPoint image.
+	info = &GeoInfo{Keys: &GeoKeyDirectory{}}
+	info.Keys.SetInts(GeoKeyType_GTModelTypeGeoKey, int64(GeoKeyValue_ModelType_Projected))
+	info.Keys.SetInts(GeoKeyType_GTRasterTypeGeoKey, int64(GeoKeyValue_RasterPixelIsPoint))
+	info.Keys.SetInts(GeoKeyType_ProjectedCSTypeGeoKey, 32631)
+	info.Keys.SetString(GeoKeyType_GTCitationGeoKey, "WGS 84 / UTM zone 31N")
+	info.Keys.SetFloats(GeoKeyType_GeogTOWGS84GeoKey, 1, 2, 3)
+	transform := GeoTransform{500000, 8, 2, 4000000, 2, -8}
+	info.SetGeoTransform(transform)
+	if info.ModelTransformation == nil || info.ModelTiepoint != nil {
+		t.Fatalf("SetGeoTransform: %+v, want a transformation matrix", info)
+	}
+	out = NewWriteAtBuffer(nil)
+	if err := Encode(out, img, &Options{Geo: info}); err != nil {
+		t.Fatal(err)
+	}
+	want := *info
+	want.Keys = &GeoKeyDirectory{Version: 1, Revision: 1, KeyMap: info.Keys.KeyMap}
+	check("PixelIsPoint", out.Bytes(), &want, transform)
+
+	info.Keys.SetString(GeoKeyType_GTCitationGeoKey, "a|b")
+	if err := Encode(NewWriteAtBuffer(nil), img, &Options{Geo: info}); err == nil {
+		t.Fatal("Encode: no error for an ASCII key holding a '|'")
+	}
+}
+
+func TestEncodeOverviewTags(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "geotiff/gdal_eg/cea.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// The GeoTIFF tags are copied from EntryMap, as when re-encoding.
+	opt := Options{EntryMap: make(map[TagType]*IFDEntry), Overviews: 2}
+	for tag, entry := range p.Ifd[0][0].EntryMap {
+		if geoTags[tag] {
+			opt.EntryMap[tag] = entry
+		}
+	}
+	if !opt.SetDPI(300, 300) {
+		t.Fatal("SetDPI failed")
+	}
+	img := image.NewGray(image.Rect(0, 0, 300, 300))
+
+	for _, encode := range []struct {
+		name string
+		fn   func(w EncoderWriter, m image.Image, opt *Options) error
+	}{
+		{"Encode", Encode},
+		{"EncodeCOG", EncodeCOG},
+	} {
+		out := NewWriteAtBuffer(nil)
+		if err := encode.fn(out, img, &opt); err != nil {
+			t.Fatalf("%s: %v", encode.name, err)
+		}
+		q, err := OpenReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", encode.name, err)
+		}
+		if len(q.Ifd) != 3 {
+			t.Fatalf("%s: %d IFDs, want 3", encode.name, len(q.Ifd))
+		}
+		for i, dpi := range []float64{300, 150, 75} {
+			ifd := q.Ifd[i][0]
+			if x, y, ok := ifd.DPI(); !ok || x != dpi || y != dpi {
+				t.Fatalf("%s: IFD %d: DPI = %v, %v, %v, want %v", encode.name, i, x, y, ok, dpi)
+			}
+			if _, ok := ifd.GeoTransform(); ok != (i == 0) {
+				t.Fatalf("%s: IFD %d: georeferenced = %v", encode.name, i, ok)
+			}
+			for tag := range geoTags {
+				if _, ok := ifd.EntryMap[tag]; ok && i != 0 {
+					t.Fatalf("%s: IFD %d: unexpected %v", encode.name, i, tag)
+				}
+			}
+		}
+		q.Close()
+	}
+}
+
 func benchmarkEncode(b *testing.B, name string, pixelSize int) {
 	img, err := openImage(name)
 	if err != nil {
@@ -66,6 +66,14 @@ func main() {
 			TypeName: "TagValue_PhotometricType",
 			FileName: "tiff_types.go",
 		},
+		Type{
+			TypeName: "TagValue_FillOrderType",
+			FileName: "tiff_types.go",
+		},
+		Type{
+			TypeName: "TagValue_OrientationType",
+			FileName: "tiff_types.go",
+		},
 		Type{
 			TypeName: "TagValue_PredictorType",
 			FileName: "tiff_types.go",
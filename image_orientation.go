@@ -0,0 +1,125 @@
+// Copyright 2015 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"image"
+	"image/color"
+)
+
+func (p TagValue_OrientationType) Valid() bool {
+	return p >= TagValue_OrientationType_TopLeft && p <= TagValue_OrientationType_LeftBottom
+}
+
+// Transposed reports whether the stored rows become columns when the
+// image is displayed, i.e. whether width and height are swapped.
+func (p TagValue_OrientationType) Transposed() bool {
+	return p >= TagValue_OrientationType_LeftTop && p <= TagValue_OrientationType_LeftBottom
+}
+
+// Bounds returns the display bounds of an image stored with bounds r.
+func (p TagValue_OrientationType) Bounds(r image.Rectangle) image.Rectangle {
+	if p.Transposed() {
+		return image.Rect(r.Min.X, r.Min.Y, r.Min.X+r.Dy(), r.Min.Y+r.Dx())
+	}
+	return r
+}
+
+// point maps the stored pixel (x, y) of a w x h image, relative to the
+// image origin, to its display position.
+func (p TagValue_OrientationType) point(x, y, w, h int) (int, int) {
+	switch p {
+	case TagValue_OrientationType_TopRight:
+		return w - 1 - x, y
+	case TagValue_OrientationType_BottomRight:
+		return w - 1 - x, h - 1 - y
+	case TagValue_OrientationType_BottomLeft:
+		return x, h - 1 - y
+	case TagValue_OrientationType_LeftTop:
+		return y, x
+	case TagValue_OrientationType_RightTop:
+		return h - 1 - y, x
+	case TagValue_OrientationType_RightBottom:
+		return h - 1 - y, w - 1 - x
+	case TagValue_OrientationType_LeftBottom:
+		return y, w - 1 - x
+	}
+	return x, y
+}
+
+// ApplyOrientation returns a new image with the stored pixels of m moved to
+// their display position. The origin of m is preserved. m is returned
+// unchanged for TopLeft or invalid orientations.
+func ApplyOrientation(m image.Image, o TagValue_OrientationType) image.Image {
+	if !o.Valid() || o == TagValue_OrientationType_TopLeft {
+		return m
+	}
+	r := o.Bounds(m.Bounds())
+
+	switch m := m.(type) {
+	case *image.Gray:
+		dst := image.NewGray(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 1, o)
+		return dst
+	case *image.Gray16:
+		dst := image.NewGray16(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 2, o)
+		return dst
+	case *image.Paletted:
+		dst := image.NewPaletted(r, m.Palette)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 1, o)
+		return dst
+	case *image.RGBA:
+		dst := image.NewRGBA(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 4, o)
+		return dst
+	case *image.RGBA64:
+		dst := image.NewRGBA64(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 8, o)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 4, o)
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 8, o)
+		return dst
+	case *image.CMYK:
+		dst := image.NewCMYK(r)
+		orientPix(dst.Pix, dst.Stride, m.Pix, m.Stride, m.Rect, 4, o)
+		return dst
+	case *MemPImage:
+		dst := NewMemPImage(r, m.XChannels, m.XDataType)
+		orientPix(dst.XPix, dst.XStride, m.XPix, m.XStride, m.XRect, SizeofPixel(m.XChannels, m.XDataType), o)
+		return dst
+	}
+
+	// Slow path for images without addressable pixels, such as YCbCr.
+	b := m.Bounds()
+	dst := image.NewRGBA64(r)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			dx, dy := o.point(x-b.Min.X, y-b.Min.Y, b.Dx(), b.Dy())
+			dst.Set(r.Min.X+dx, r.Min.Y+dy, color.RGBA64Model.Convert(m.At(x, y)))
+		}
+	}
+	return dst
+}
+
+// orientPix copies the pixels of src (bounds r, bpp bytes per pixel) into
+// dst at their display position for orientation o.
+func orientPix(dst []byte, dstStride int, src []byte, srcStride int, r image.Rectangle, bpp int, o TagValue_OrientationType) {
+	w, h := r.Dx(), r.Dy()
+	for y := 0; y < h; y++ {
+		off := y * srcStride
+		for x := 0; x < w; x++ {
+			dx, dy := o.point(x, y, w, h)
+			i := dy*dstStride + dx*bpp
+			copy(dst[i:i+bpp], src[off:off+bpp])
+			off += bpp
+		}
+	}
+}
@@ -7,28 +7,64 @@ package tiff
 import (
 	"fmt"
 	"image"
+	"reflect"
 )
 
-func newImageWithIFD(r image.Rectangle, ifd *IFD) (m image.Image, err error) {
+// checkRawSamples reports why the stored samples of ifd can not be decoded
+// as they are, as with DecodeOptions.KeepRawSamples.
+func (p *IFD) checkRawSamples() error {
+	switch p.ImageType() {
+	case ImageType_YCbCr, ImageType_Nil:
+		return fmt.Errorf("raw samples are not supported for %v", p.ImageType())
+	}
+	switch p.Compression() {
+	case TagValue_CompressionType_JPEGOld, TagValue_CompressionType_JPEG:
+		// The codec returns the decoded colors, not the stored samples.
+		return fmt.Errorf("raw samples are not supported for %v", p.Compression())
+	}
+	if p.Kind() == reflect.Invalid {
+		return fmt.Errorf("unsupported sample type for raw samples")
+	}
+	return nil
+}
+
+func newImageWithIFD(r image.Rectangle, ifd *IFD, opt *DecodeOptions) (m image.Image, err error) {
+	if opt == nil {
+		opt = &DecodeOptions{}
+	}
+	if opt.KeepRawSamples {
+		if err = ifd.checkRawSamples(); err != nil {
+			err = fmt.Errorf("tiff: Decode, %v", err)
+			return
+		}
+		m = NewMemPImage(r, ifd.Channels(), ifd.Kind())
+		return
+	}
+
+	is16Bit := ifd.Depth() == 16 && !opt.Force8Bit
 	switch ifd.ImageType() {
 	case ImageType_Bilevel, ImageType_BilevelInvert:
 		m = image.NewGray(r)
 	case ImageType_Gray, ImageType_GrayInvert:
-		if ifd.Depth() == 16 {
+		if is16Bit {
 			m = image.NewGray16(r)
 		} else {
 			m = image.NewGray(r)
 		}
 	case ImageType_Paletted:
-		m = image.NewPaletted(r, ifd.ColorMap())
+		if opt.ExpandPalette {
+			m = image.NewRGBA(r)
+		} else {
+			m = image.NewPaletted(r, ifd.ColorMap())
+		}
 	case ImageType_NRGBA:
-		if ifd.Depth() == 16 {
+		if is16Bit {
 			m = image.NewNRGBA64(r)
 		} else {
 			m = image.NewNRGBA(r)
 		}
 	case ImageType_RGB, ImageType_RGBA:
-		if ifd.Depth() == 16 {
+		if is16Bit {
 			m = image.NewRGBA64(r)
 		} else {
 			m = image.NewRGBA(r)
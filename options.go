@@ -4,8 +4,49 @@
 
 package tiff
 
+import (
+	"image"
+	"math"
+)
+
 type Options struct {
+	// EntryMap holds the tags of the written images. Compression, Predictor,
+	// FillOrder, TileWidth, TileLength and RowsPerStrip select how the
+	// pixels are stored. The tags describing the stored pixels, such as
+	// ImageWidth or StripOffsets, and the tags pointing to other IFDs are
+	// set by the encoder. All the other tags are written as they are. The
+	// resolution is 72x72 dpi unless set.
 	EntryMap map[TagType]*IFDEntry
+
+	// Exif and GPS hold the entries of the Exif and GPS IFDs of the
+	// written image, such as those read in IFD.Exif and IFD.GPS. They are
+	// written as IFDs of their own, referenced by the ExifIFD and GPSIFD
+	// tags of the image. The overviews have no Exif or GPS IFD.
+	Exif map[ExifIFD_TagType]*IFDEntry
+	GPS  map[GPSIFD_TagType]*IFDEntry
+
+	// Geo georeferences the written image, such as the GeoInfo read with
+	// IFD.GeoInfo. Its tags replace the GeoTIFF tags of EntryMap. The
+	// overviews are not georeferenced.
+	Geo *GeoInfo
+
+	// Overviews is the number of reduced resolution images written for
+	// the image, each one half the size of the previous one. The overviews
+	// stop early when the image can not be halved anymore. They have the
+	// tags of EntryMap, but for the GeoTIFF tags, and half the resolution
+	// of the previous one.
+	Overviews int
+
+	// OverviewFilter is the filter used to downsample the overviews.
+	OverviewFilter ResampleFilter
+
+	// OverviewsAsSubIFDs writes the overviews as SubIFDs of the image,
+	// instead of chaining them as top-level IFDs right after the image.
+	OverviewsAsSubIFDs bool
+
+	// Workers is the number of goroutines compressing the strips or tiles
+	// of an image, zero uses runtime.GOMAXPROCS(0).
+	Workers int
 }
 
 func (p *Options) TagGetter() TagGetter {
@@ -14,8 +55,131 @@ func (p *Options) TagGetter() TagGetter {
 	}
 }
 
+// TagSetter returns a TagSetter adding the entries to p.EntryMap, which is
+// created when nil.
 func (p *Options) TagSetter() TagSetter {
+	if p.EntryMap == nil {
+		p.EntryMap = make(map[TagType]*IFDEntry)
+	}
 	return &tifTagSetter{
 		EntryMap: p.EntryMap,
 	}
 }
+
+// SetResolution sets the XResolution, YResolution and ResolutionUnit tags
+// of the written images to x and y pixels per unit. It reports whether the
+// values are valid: positive and, as rationals, within 32 bits.
+func (p *Options) SetResolution(x, y float64, unit TagValue_ResolutionUnitType) (ok bool) {
+	rx, ok1 := floatToRational(x)
+	ry, ok2 := floatToRational(y)
+	if !ok1 || !ok2 {
+		return false
+	}
+	setter := p.TagSetter()
+	return setter.SetXResolution(rx) && setter.SetYResolution(ry) && setter.SetResolutionUnit(unit)
+}
+
+// SetDPI sets the resolution of the written images in pixels per inch.
+func (p *Options) SetDPI(x, y float64) (ok bool) {
+	return p.SetResolution(x, y, TagValue_ResolutionUnitType_PerInch)
+}
+
+// SetDPCM sets the resolution of the written images in pixels per
+// centimeter.
+func (p *Options) SetDPCM(x, y float64) (ok bool) {
+	return p.SetResolution(x, y, TagValue_ResolutionUnitType_PerCM)
+}
+
+// SetResolutionFrom sets the resolution of the written images to the one
+// of ifd, such as an image being re-encoded. It reports whether ifd has a
+// valid resolution.
+func (p *Options) SetResolutionFrom(ifd *IFD) (ok bool) {
+	_, _, unit, ok := ifd.Resolution()
+	if !ok {
+		return
+	}
+	getter := ifd.TagGetter()
+	rx, _ := getter.GetXResolution()
+	ry, _ := getter.GetYResolution()
+	setter := p.TagSetter()
+	return setter.SetXResolution(rx) && setter.SetYResolution(ry) && setter.SetResolutionUnit(unit)
+}
+
+// SetGDALMetadata sets the GDAL_METADATA tag of the written images to md.
+// It fails when md is nil.
+func (p *Options) SetGDALMetadata(md *GDALMetadata) (ok bool) {
+	if md == nil {
+		return false
+	}
+	return p.TagSetter().SetUnknown(TagType_GDAL_METADATA, md.String())
+}
+
+// SetGDALNoData sets the GDAL_NODATA tag of the written images: the value
+// of the samples which have no data.
+func (p *Options) SetGDALNoData(v float64) (ok bool) {
+	return p.TagSetter().SetUnknown(TagType_GDAL_NODATA, formatGDALFloat(v))
+}
+
+// SetXMP embeds the XMP packet, an XML document, in the written images.
+func (p *Options) SetXMP(packet []byte) (ok bool) {
+	return p.TagSetter().SetXMP(packet)
+}
+
+// floatToRational returns v as a positive rational, exact for integers and
+// to 1/10000 otherwise.
+func floatToRational(v float64) (r [2]int64, ok bool) {
+	const den = 10000
+	if !(v > 0) || v*den > 0xffffffff {
+		return
+	}
+	if v == math.Trunc(v) {
+		return [2]int64{int64(v), 1}, true
+	}
+	r = [2]int64{int64(math.Round(v * den)), den}
+	if r[0] == 0 {
+		return r, false
+	}
+	for a, b := r[0], r[1]; ; {
+		if a, b = b, a%b; b == 0 {
+			r[0], r[1] = r[0]/a, r[1]/a
+			break
+		}
+	}
+	return r, true
+}
+
+// DecodeOptions are the options used when decoding an image.
+// A nil *DecodeOptions decodes the stored pixels as is.
+type DecodeOptions struct {
+	// ApplyOrientation moves the decoded pixels to their display position
+	// as described by the Orientation tag.
+	ApplyOrientation bool
+
+	// ExpandPalette decodes paletted images into *image.RGBA.
+	ExpandPalette bool
+
+	// Force8Bit decodes 16-bit images into their 8-bit counterparts.
+	Force8Bit bool
+
+	// KeepRawSamples decodes the stored samples into a *MemPImage without
+	// any color conversion. Samples smaller than one byte are unpacked into
+	// one byte each. It takes precedence over ExpandPalette and Force8Bit.
+	// YCbCr and JPEG compressed images are not supported.
+	KeepRawSamples bool
+
+	// MaskNoData sets the float samples decoded with KeepRawSamples to NaN
+	// when they are equal to the GDAL_NODATA value of the image.
+	MaskNoData bool
+
+	// MaxPixels is the largest number of pixels which may be decoded, a
+	// larger image (or Rect) returns an error. Zero means no limit.
+	MaxPixels int64
+
+	// Workers is the number of goroutines decoding the blocks of an image,
+	// zero uses runtime.GOMAXPROCS(0).
+	Workers int
+
+	// Rect is the part of the image to decode, in stored pixel coordinates
+	// (before ApplyOrientation). An empty Rect decodes the whole image.
+	Rect image.Rectangle
+}
@@ -10,16 +10,28 @@ import (
 	"io"
 )
 
+// Reader reads the images of a TIFF file.
+//
+// The decoding methods (DecodeImage, DecodeImageWithOptions, DecodeRegion
+// and DecodeImageBlock) are safe to call from several goroutines at once:
+// the blocks are fetched with positional reads, which are serialized by a
+// lock when the underlying reader is only an io.ReadSeeker. Close, changes
+// to Ifd and direct use of the Reader field must not run concurrently with
+// them.
 type Reader struct {
 	Reader io.ReadSeeker
 	Header *Header
 	Ifd    [][]*IFD
 
-	rs *seekioReader
+	rs    seekReadCloser
+	cache *blockCache
 }
 
 func OpenReader(r io.Reader) (p *Reader, err error) {
-	rs := openSeekioReader(r, -1)
+	return openReader(openSeekioReader(r, -1))
+}
+
+func openReader(rs seekReadCloser) (p *Reader, err error) {
 	defer func() {
 		if err != nil && rs != nil {
 			rs.Close()
@@ -42,12 +54,14 @@ func OpenReader(r io.Reader) (p *Reader, err error) {
 		if ifd, err = ReadIFD(rs, p.Header, offset); err != nil {
 			return
 		}
+		readExifIFDs(rs, ifd)
 		ifdList = append(ifdList, ifd)
 		offset = ifd.NextIFD
 
 		subIfdOffsets, _ := ifd.TagGetter().GetSubIFD()
 		for _, subOffset := range subIfdOffsets {
 			ifd, _ = ReadIFD(rs, p.Header, subOffset)
+			readExifIFDs(rs, ifd)
 			ifdList = append(ifdList, ifd)
 		}
 		p.Ifd = append(p.Ifd, ifdList)
@@ -82,39 +96,213 @@ func (p *Reader) ImageBlockBounds(i, j, col, row int) image.Rectangle {
 }
 
 func (p *Reader) DecodeImage(i, j int) (m image.Image, err error) {
+	return p.DecodeImageWithOptions(i, j, nil)
+}
+
+// DecodeImageWithOptions decodes the sub image j of image i using opt.
+// Only the blocks which intersect opt.Rect are read.
+func (p *Reader) DecodeImageWithOptions(i, j int, opt *DecodeOptions) (m image.Image, err error) {
 	cfg, err := p.ImageConfig(i, j)
 	if err != nil {
 		return
 	}
 	imgRect := image.Rect(0, 0, cfg.Width, cfg.Height)
-	if m, err = newImageWithIFD(imgRect, p.Ifd[i][j]); err != nil {
+	if opt != nil && !opt.Rect.Empty() {
+		if imgRect = opt.Rect.Intersect(imgRect); imgRect.Empty() {
+			err = fmt.Errorf("tiff: Reader.DecodeImage, rect %v is outside of the image", opt.Rect)
+			return
+		}
+	}
+	if opt != nil && opt.MaxPixels > 0 {
+		if n := int64(imgRect.Dx()) * int64(imgRect.Dy()); n > opt.MaxPixels {
+			err = fmt.Errorf("tiff: Reader.DecodeImage, %d pixels exceed the limit of %d", n, opt.MaxPixels)
+			return
+		}
+	}
+	if m, err = p.decodeRegion(i, j, imgRect, opt); err != nil {
+		return
+	}
+
+	if opt != nil && opt.ApplyOrientation {
+		m = ApplyOrientation(m, p.Ifd[i][j].Orientation())
+	}
+	return
+}
+
+// DecodeRegion decodes the part rect of the sub image j of image i.
+// Only the strips or tiles which intersect rect are read, the returned
+// image has exactly the bounds rect. Pixels of rect which are outside of
+// the image are left zero.
+func (p *Reader) DecodeRegion(i, j int, rect image.Rectangle) (m image.Image, err error) {
+	if rect.Empty() {
+		err = fmt.Errorf("tiff: Reader.DecodeRegion, empty rect %v", rect)
+		return
+	}
+	return p.decodeRegion(i, j, rect, nil)
+}
+
+func (p *Reader) decodeRegion(i, j int, rect image.Rectangle, opt *DecodeOptions) (m image.Image, err error) {
+	if m, err = newImageWithIFD(rect, p.Ifd[i][j], opt); err != nil {
 		return
 	}
 
-	blocksAcross := p.ImageBlocksAcross(i, j)
-	blocksDown := p.ImageBlocksDown(i, j)
+	// The cached blocks have the default layout, they are used only when
+	// opt does not change it.
+	cache := p.cache
+	if opt != nil && (opt.ExpandPalette || opt.Force8Bit || opt.KeepRawSamples) {
+		cache = nil
+	}
 
-	for col := 0; col < blocksAcross; col++ {
-		for row := 0; row < blocksDown; row++ {
-			if err = p.Ifd[i][j].DecodeBlock(p.rs, col, row, m); err != nil {
-				return
+	ifd := p.Ifd[i][j]
+	var blocks []blockRange
+	for row := 0; row < ifd.BlocksDown(); row++ {
+		for col := 0; col < ifd.BlocksAcross(); col++ {
+			if !ifd.BlockBounds(col, row).Overlaps(rect) {
+				continue
+			}
+			if cache != nil {
+				if b, ok := cache.Get(blockCacheKey{i, j, col, row}); ok {
+					copyImage(m, b)
+					continue
+				}
+			}
+			blocks = append(blocks, blockRange{col, row, ifd.BlockOffset(col, row), ifd.BlockCount(col, row)})
+		}
+	}
+
+	data, err := readBlocks(p.rs, ifd, blocks)
+	if err != nil {
+		return
+	}
+	// The blocks cover disjoint parts of m, they are decoded in parallel.
+	var workers int
+	if opt != nil {
+		workers = opt.Workers
+	}
+	err = parallelDo(len(blocks), workers, func(k int) (err error) {
+		col, row := blocks[k].col, blocks[k].row
+		if cache == nil {
+			return ifd.decodeBlockData(data[k], col, row, m, opt)
+		}
+		b, err := newImageWithIFD(ifd.BlockBounds(col, row), ifd, nil)
+		if err != nil {
+			return
+		}
+		if err = ifd.decodeBlockData(data[k], col, row, b, nil); err != nil {
+			return
+		}
+		cache.Put(blockCacheKey{i, j, col, row}, b)
+		copyImage(m, b)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	if opt != nil && opt.MaskNoData {
+		if nodata, ok := ifd.GDALNoData(); ok {
+			if mp, ok := m.(*MemPImage); ok {
+				maskNoData(mp, nodata)
 			}
 		}
 	}
 	return
 }
 
+// DecodeImageBlock decodes the block (col, row) of the sub image j of
+// image i. When the block cache is enabled, the returned image is shared
+// with the cache and must not be modified.
 func (p *Reader) DecodeImageBlock(i, j, col, row int) (m image.Image, err error) {
+	key := blockCacheKey{i, j, col, row}
+	if p.cache != nil {
+		if m, ok := p.cache.Get(key); ok {
+			return m, nil
+		}
+	}
 	r := p.ImageBlockBounds(i, j, col, row)
-	if m, err = newImageWithIFD(r, p.Ifd[i][j]); err != nil {
+	if m, err = newImageWithIFD(r, p.Ifd[i][j], nil); err != nil {
+		return
+	}
+	if err = p.Ifd[i][j].DecodeBlock(p.rs, col, row, m, nil); err != nil {
+		return
+	}
+	if p.cache != nil {
+		p.cache.Put(key, m)
+	}
+	return
+}
+
+// ReadRawBlock returns the stored bytes of the block (col, row) of the sub
+// image j of image i, still compressed, and the IFD describing them.
+func (p *Reader) ReadRawBlock(i, j, col, row int) (data []byte, ifd *IFD, err error) {
+	if ifd, err = p.subImage("ReadRawBlock", i, j); err != nil {
+		return
+	}
+	if data, err = ifd.readBlock(p.rs, col, row); err != nil {
 		return
 	}
-	if err = p.Ifd[i][j].DecodeBlock(p.rs, col, row, m); err != nil {
+	if n := ifd.BlockCount(col, row); int64(len(data)) != n {
+		err = fmt.Errorf("tiff: Reader.ReadRawBlock, read %d bytes of %d", len(data), n)
 		return
 	}
 	return
 }
 
+// ReadRawImage returns the sub image j of image i with all its blocks,
+// still compressed, ready to be written by EncodeRaw.
+func (p *Reader) ReadRawImage(i, j int) (m *RawImage, err error) {
+	ifd, err := p.subImage("ReadRawImage", i, j)
+	if err != nil {
+		return
+	}
+	var blocks []blockRange
+	for row := 0; row < ifd.BlocksDown(); row++ {
+		for col := 0; col < ifd.BlocksAcross(); col++ {
+			blocks = append(blocks, blockRange{col, row, ifd.BlockOffset(col, row), ifd.BlockCount(col, row)})
+		}
+	}
+	m = &RawImage{Ifd: ifd}
+	if m.Blocks, err = readBlocks(p.rs, ifd, blocks); err != nil {
+		return
+	}
+	for k, b := range blocks {
+		if int64(len(m.Blocks[k])) != b.count {
+			err = fmt.Errorf("tiff: Reader.ReadRawImage, read %d bytes of %d", len(m.Blocks[k]), b.count)
+			return
+		}
+	}
+	return
+}
+
+// ReadRawPage returns the image i, with its SubIFDs, and all their blocks,
+// still compressed, ready to be written by EncodeRaw.
+func (p *Reader) ReadRawPage(i int) (m *RawImage, err error) {
+	if m, err = p.ReadRawImage(i, 0); err != nil {
+		return
+	}
+	for j := 1; j < p.SubImageNum(i); j++ {
+		if p.Ifd[i][j] == nil {
+			continue
+		}
+		var sub *RawImage
+		if sub, err = p.ReadRawImage(i, j); err != nil {
+			return
+		}
+		m.SubIFDs = append(m.SubIFDs, sub)
+	}
+	return
+}
+
+// subImage returns the IFD of the sub image j of image i, or an error for
+// the method name when there is no such image.
+func (p *Reader) subImage(name string, i, j int) (ifd *IFD, err error) {
+	if i < 0 || i >= len(p.Ifd) || j < 0 || j >= len(p.Ifd[i]) || p.Ifd[i][j] == nil {
+		err = fmt.Errorf("tiff: Reader.%s, bad image index %d/%d", name, i, j)
+		return
+	}
+	return p.Ifd[i][j], nil
+}
+
 func (p *Reader) Close() (err error) {
 	if p != nil {
 		if p.rs != nil {
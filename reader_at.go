@@ -0,0 +1,217 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+var _ seekReadCloser = (*readerAtSeeker)(nil)
+
+// readerAtPageSize is the size of the reads done by readerAtSeeker for the
+// small reads of the header and the IFDs.
+const readerAtPageSize = 16 << 10
+
+// readerAtMaxGap is the largest gap between two blocks which are still
+// fetched with a single read.
+const readerAtMaxGap = 4 << 10
+
+// readerAtMaxRead is the largest read merging several blocks, so that a
+// full decode does not fetch the whole image at once. A larger block is
+// still read alone.
+const readerAtMaxRead = 8 << 20
+
+// readerAtSeeker is an io.ReadSeeker over an io.ReaderAt. Sequential reads
+// go through a one page buffer, so that reading the entries of an IFD, or
+// the values stored next to it, does not issue a ReadAt per field.
+// ReadAt is passed through unbuffered.
+type readerAtSeeker struct {
+	r    io.ReaderAt
+	size int64
+	off  int64
+
+	page    []byte
+	pageOff int64
+}
+
+func newReaderAtSeeker(r io.ReaderAt, size int64) *readerAtSeeker {
+	return &readerAtSeeker{r: r, size: size}
+}
+
+func (p *readerAtSeeker) Read(data []byte) (n int, err error) {
+	if p.off >= p.size {
+		return 0, io.EOF
+	}
+	if p.off < p.pageOff || p.off >= p.pageOff+int64(len(p.page)) {
+		// Data larger than a page is read directly.
+		if len(data) >= readerAtPageSize {
+			n, err = p.ReadAt(data, p.off)
+			p.off += int64(n)
+			return
+		}
+		if err = p.fill(p.off); err != nil {
+			return
+		}
+	}
+	n = copy(data, p.page[p.off-p.pageOff:])
+	p.off += int64(n)
+	return
+}
+
+// fill reads the page starting at off.
+func (p *readerAtSeeker) fill(off int64) (err error) {
+	size := int64(readerAtPageSize)
+	if off+size > p.size {
+		size = p.size - off
+	}
+	if cap(p.page) < int(size) {
+		p.page = make([]byte, size)
+	}
+	p.page = p.page[:size]
+
+	n, err := p.r.ReadAt(p.page, off)
+	if err == io.EOF && n == len(p.page) {
+		err = nil
+	}
+	p.page, p.pageOff = p.page[:n], off
+	return
+}
+
+func (p *readerAtSeeker) ReadAt(data []byte, off int64) (n int, err error) {
+	if off >= p.size {
+		return 0, io.EOF
+	}
+	if remain := p.size - off; int64(len(data)) > remain {
+		n, err = p.r.ReadAt(data[:remain], off)
+		if err == nil {
+			err = io.EOF
+		}
+		return
+	}
+	return p.r.ReadAt(data, off)
+}
+
+// Size returns the size of the file.
+func (p *readerAtSeeker) Size() int64 {
+	return p.size
+}
+
+func (p *readerAtSeeker) Seek(offset int64, whence int) (ret int64, err error) {
+	switch whence {
+	case 0:
+		ret = offset
+	case 1:
+		ret = p.off + offset
+	case 2:
+		ret = p.size + offset
+	default:
+		return p.off, fmt.Errorf("tiff: readerAtSeeker.Seek, bad whence %d", whence)
+	}
+	if ret < 0 {
+		return p.off, fmt.Errorf("tiff: readerAtSeeker.Seek, negative offset %d", ret)
+	}
+	p.off = ret
+	return
+}
+
+func (p *readerAtSeeker) Close() error {
+	return nil
+}
+
+// OpenReaderAt opens the TIFF file of size bytes in r.
+//
+// Unlike OpenReader, only the header, the IFDs and the blocks which are
+// decoded are read from r, the rest of the file is never fetched. The IFDs
+// are read in pages of 16KB, and the blocks needed by DecodeImage or
+// DecodeRegion which are (nearly) contiguous in the file are fetched with a
+// single ReadAt of up to 8MB. This makes r a good fit for HTTP range
+// requests.
+//
+// The values of the entries larger than 4KB, such as the tile offsets of a
+// large image, an ICC profile or an XMP packet, are read from r when they
+// are first used, so r must stay readable while the IFDs are in use.
+func OpenReaderAt(r io.ReaderAt, size int64) (p *Reader, err error) {
+	return openReader(newReaderAtSeeker(r, size))
+}
+
+// blockRange is the position of a block in the file.
+type blockRange struct {
+	col, row      int
+	offset, count int64
+}
+
+// readBlocks returns the stored bytes of the blocks of ifd at the given
+// positions, in the same order. When r is an io.ReaderAt, neighbouring
+// blocks are fetched with a single read.
+func readBlocks(r io.ReadSeeker, ifd *IFD, blocks []blockRange) (data [][]byte, err error) {
+	data = make([][]byte, len(blocks))
+
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		for i, b := range blocks {
+			if data[i], err = ifd.readBlock(r, b.col, b.row); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	// Sort the blocks by offset, then read every run of blocks whose gaps
+	// are small at once.
+	order := make([]int, len(blocks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return blocks[order[a]].offset < blocks[order[b]].offset })
+
+	for i := 0; i < len(order); {
+		start, end := blocks[order[i]].offset, blocks[order[i]].offset+blocks[order[i]].count
+		j := i + 1
+		for ; j < len(order); j++ {
+			b := blocks[order[j]]
+			if b.offset > end+readerAtMaxGap || b.offset+b.count-start > readerAtMaxRead {
+				break
+			}
+			if b.offset+b.count > end {
+				end = b.offset + b.count
+			}
+		}
+		if start < 0 {
+			err = fmt.Errorf("tiff: IFD.DecodeBlock, bad block offset = %d", start)
+			return
+		}
+		if sz, ok := r.(interface{ Size() int64 }); ok && sz.Size() >= 0 && end > sz.Size() {
+			end = sz.Size()
+		}
+		if end < start {
+			end = start
+		}
+
+		var n int
+		buf := make([]byte, end-start)
+		if n, err = ra.ReadAt(buf, start); err == io.EOF {
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+		buf = buf[:n]
+
+		for ; i < j; i++ {
+			b := blocks[order[i]]
+			lo, hi := b.offset-start, b.offset-start+b.count
+			if hi > int64(len(buf)) {
+				hi = int64(len(buf))
+			}
+			if lo > hi {
+				lo = hi
+			}
+			data[order[i]] = buf[lo:hi]
+		}
+	}
+	return
+}
@@ -0,0 +1,176 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"container/list"
+	"image"
+	"image/color"
+	"sync"
+)
+
+type blockCacheKey struct {
+	i, j, col, row int
+}
+
+type blockCacheEntry struct {
+	key  blockCacheKey
+	m    image.Image
+	size int
+}
+
+// blockCache is a LRU cache of decoded blocks, limited to maxSize bytes as
+// given by SizeofImage. It is safe for concurrent use.
+type blockCache struct {
+	mu      sync.Mutex
+	maxSize int
+	size    int
+	lru     *list.List // Most recently used first.
+	items   map[blockCacheKey]*list.Element
+}
+
+func newBlockCache(maxSize int) *blockCache {
+	return &blockCache{
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[blockCacheKey]*list.Element),
+	}
+}
+
+func (p *blockCache) Get(key blockCacheKey) (m image.Image, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.items[key]; ok {
+		p.lru.MoveToFront(e)
+		return e.Value.(*blockCacheEntry).m, true
+	}
+	return nil, false
+}
+
+func (p *blockCache) Put(key blockCacheKey, m image.Image) {
+	size := SizeofImage(m)
+	if size > p.maxSize {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.items[key]; ok {
+		p.size -= e.Value.(*blockCacheEntry).size
+		p.lru.Remove(e)
+	}
+	p.items[key] = p.lru.PushFront(&blockCacheEntry{key: key, m: m, size: size})
+	p.size += size
+
+	for p.size > p.maxSize {
+		e := p.lru.Back()
+		entry := e.Value.(*blockCacheEntry)
+		p.lru.Remove(e)
+		delete(p.items, entry.key)
+		p.size -= entry.size
+	}
+}
+
+// Size returns the number of bytes used by the cached blocks.
+func (p *blockCache) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
+}
+
+// SetBlockCacheSize enables a LRU cache of the decoded blocks of up to size
+// bytes, as given by SizeofImage. A size of zero disables the cache.
+//
+// With the cache, DecodeImageBlock returns the cached image, which must not
+// be modified. DecodeImage and DecodeRegion copy the cached blocks, unless
+// they are decoded with ExpandPalette, Force8Bit or KeepRawSamples.
+//
+// SetBlockCacheSize must not run concurrently with the decoding methods.
+func (p *Reader) SetBlockCacheSize(size int) {
+	if size <= 0 {
+		p.cache = nil
+		return
+	}
+	p.cache = newBlockCache(size)
+}
+
+// BlockCacheSize returns the number of bytes used by the cached blocks.
+func (p *Reader) BlockCacheSize() int {
+	if p.cache == nil {
+		return 0
+	}
+	return p.cache.Size()
+}
+
+// copyImage copies the pixels of src which are inside dst into dst.
+// Both images must be of the same type, as created by newImageWithIFD.
+func copyImage(dst, src image.Image) {
+	r := dst.Bounds().Intersect(src.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	var dstPix, srcPix []byte
+	var dstStride, srcStride, dstOff, srcOff, pixelSize int
+	switch dst := dst.(type) {
+	case *image.Gray:
+		src := src.(*image.Gray)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 1
+	case *image.Gray16:
+		src := src.(*image.Gray16)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 2
+	case *image.Paletted:
+		src := src.(*image.Paletted)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 1
+	case *image.RGBA:
+		src := src.(*image.RGBA)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 4
+	case *image.RGBA64:
+		src := src.(*image.RGBA64)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 8
+	case *image.NRGBA:
+		src := src.(*image.NRGBA)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 4
+	case *image.NRGBA64:
+		src := src.(*image.NRGBA64)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 8
+	case *image.CMYK:
+		src := src.(*image.CMYK)
+		dstPix, dstStride, dstOff = dst.Pix, dst.Stride, dst.PixOffset(r.Min.X, r.Min.Y)
+		srcPix, srcStride, srcOff = src.Pix, src.Stride, src.PixOffset(r.Min.X, r.Min.Y)
+		pixelSize = 4
+	default:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				dst.(interface {
+					Set(x, y int, c color.Color)
+				}).Set(x, y, src.At(x, y))
+			}
+		}
+		return
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(dstPix[dstOff:dstOff+r.Dx()*pixelSize], srcPix[srcOff:])
+		dstOff += dstStride
+		srcOff += srcStride
+	}
+}
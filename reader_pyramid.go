@@ -0,0 +1,96 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Level is one resolution level of an image pyramid.
+type Level struct {
+	I, J   int // Index of the level in Reader.Ifd.
+	Width  int
+	Height int
+
+	// ScaleX and ScaleY are the size of the full resolution image divided
+	// by the size of the level, 1 for the full resolution image.
+	ScaleX float64
+	ScaleY float64
+}
+
+// Levels returns the resolution levels of image i, from the full resolution
+// image Ifd[i][0] down to the smallest overview.
+//
+// Overviews are the images with the Reduced bit of NewSubfileType set,
+// either stored as SubIFDs of image i, or chained as top-level IFDs right
+// after image i (as written by GDAL). Transparency masks are skipped.
+func (p *Reader) Levels(i int) (levels []Level, err error) {
+	if i < 0 || i >= len(p.Ifd) || len(p.Ifd[i]) == 0 || p.Ifd[i][0] == nil {
+		err = fmt.Errorf("tiff: Reader.Levels, bad image index %d", i)
+		return
+	}
+
+	base := p.Ifd[i][0].Bounds()
+	if base.Empty() {
+		err = fmt.Errorf("tiff: Reader.Levels, image %d is empty", i)
+		return
+	}
+	addLevel := func(i, j int) {
+		r := p.Ifd[i][j].Bounds()
+		if r.Empty() {
+			return
+		}
+		levels = append(levels, Level{
+			I:      i,
+			J:      j,
+			Width:  r.Dx(),
+			Height: r.Dy(),
+			ScaleX: float64(base.Dx()) / float64(r.Dx()),
+			ScaleY: float64(base.Dy()) / float64(r.Dy()),
+		})
+	}
+
+	addLevel(i, 0)
+	for j := 1; j < len(p.Ifd[i]); j++ {
+		if ifd := p.Ifd[i][j]; ifd != nil && ifd.IsReduced() && !ifd.IsMask() {
+			addLevel(i, j)
+		}
+	}
+	for k := i + 1; k < len(p.Ifd); k++ {
+		if len(p.Ifd[k]) == 0 || p.Ifd[k][0] == nil {
+			break
+		}
+		ifd := p.Ifd[k][0]
+		if ifd.IsMask() {
+			continue
+		}
+		if !ifd.IsReduced() {
+			break
+		}
+		addLevel(k, 0)
+	}
+
+	sort.SliceStable(levels, func(a, b int) bool {
+		return levels[a].Width*levels[a].Height > levels[b].Width*levels[b].Height
+	})
+	return
+}
+
+// BestLevel returns the smallest resolution level of image i which is at
+// least width x height, or the full resolution image when there is none.
+func (p *Reader) BestLevel(i, width, height int) (level Level, err error) {
+	levels, err := p.Levels(i)
+	if err != nil {
+		return
+	}
+	level = levels[0]
+	for _, v := range levels[1:] {
+		if v.Width >= width && v.Height >= height {
+			level = v
+		}
+	}
+	return
+}
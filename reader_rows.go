@@ -0,0 +1,139 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"image"
+	"reflect"
+)
+
+// RowReader reads the rows of an image one at a time:
+//
+//	rows, err := p.Rows(i, j)
+//	if err != nil {
+//		...
+//	}
+//	for rows.Next() {
+//		process(rows.Y(), rows.Row())
+//	}
+//	if err := rows.Err(); err != nil {
+//		...
+//	}
+//
+// The image is decoded one strip, or one row of tiles, at a time, so only
+// the rows of the current strip are kept in memory.
+type RowReader struct {
+	p    *Reader
+	i, j int
+	ifd  *IFD
+
+	bounds  image.Rectangle
+	workers int
+
+	band *MemPImage // The decoded blocks holding row y.
+	y    int
+	err  error
+}
+
+// Rows returns a RowReader for the sub image j of image i. The rows hold
+// the samples as stored in the file, as decoded with the KeepRawSamples
+// option: interleaved, with the type given by IFD.Kind, in the byte order
+// of the host, and with sub-byte samples expanded to a byte each. YCbCr and
+// JPEG compressed images, whose samples are converted when decoded, are
+// not supported.
+func (p *Reader) Rows(i, j int) (rows *RowReader, err error) {
+	ifd, err := p.subImage("Rows", i, j)
+	if err != nil {
+		return
+	}
+	if err = ifd.checkRawSamples(); err != nil {
+		err = fmt.Errorf("tiff: Reader.Rows, %v", err)
+		return
+	}
+	rows = &RowReader{
+		p:      p,
+		i:      i,
+		j:      j,
+		ifd:    ifd,
+		bounds: ifd.Bounds(),
+		y:      -1,
+	}
+	return
+}
+
+// SetWorkers sets the number of goroutines decoding the blocks of a row of
+// tiles, as DecodeOptions.Workers.
+func (p *RowReader) SetWorkers(n int) {
+	p.workers = n
+}
+
+// Bounds returns the bounds of the image.
+func (p *RowReader) Bounds() image.Rectangle {
+	return p.bounds
+}
+
+// Channels returns the number of samples of a pixel.
+func (p *RowReader) Channels() int {
+	return p.ifd.Channels()
+}
+
+// Kind returns the type of the samples.
+func (p *RowReader) Kind() reflect.Kind {
+	return p.ifd.Kind()
+}
+
+// Next advances to the next row. It returns false at the end of the image
+// or when an error occurs.
+func (p *RowReader) Next() bool {
+	if p.err != nil || p.y >= p.bounds.Max.Y {
+		return false
+	}
+	if p.y++; p.y >= p.bounds.Max.Y {
+		return false
+	}
+	if p.band != nil && p.y < p.band.Bounds().Max.Y {
+		return true
+	}
+
+	// Decode the strip, or the row of tiles, holding row y.
+	band := p.bounds
+	band.Min.Y = p.y
+	for row := 0; row < p.ifd.BlocksDown(); row++ {
+		if b := p.ifd.BlockBounds(0, row); p.y >= b.Min.Y && p.y < b.Max.Y {
+			band.Max.Y = minInt(b.Max.Y, p.bounds.Max.Y)
+			break
+		}
+	}
+
+	opt := &DecodeOptions{KeepRawSamples: true, Workers: p.workers}
+	m, err := p.p.decodeRegion(p.i, p.j, band, opt)
+	if err != nil {
+		p.err, p.band = err, nil
+		return false
+	}
+	p.band = m.(*MemPImage)
+	return true
+}
+
+// Y returns the index of the current row.
+func (p *RowReader) Y() int {
+	return p.y
+}
+
+// Row returns the samples of the current row. The slice is only valid
+// until the next call to Next.
+func (p *RowReader) Row() []byte {
+	if p.band == nil {
+		return nil
+	}
+	off := p.band.PixOffset(p.bounds.Min.X, p.y)
+	return p.band.XPix[off : off+p.bounds.Dx()*p.band.XChannels*SizeofKind(p.band.XDataType)]
+}
+
+// Err returns the error which stopped Next, if any.
+func (p *RowReader) Err() error {
+	return p.err
+}
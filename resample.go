@@ -0,0 +1,240 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// ResampleFilter is the filter used to downsample the overviews.
+type ResampleFilter int
+
+const (
+	ResampleFilter_Nearest ResampleFilter = iota // Top-left pixel of each 2x2 block.
+	ResampleFilter_Average                       // Mean of each 2x2 block.
+	ResampleFilter_Lanczos                       // Lanczos with 3 lobes.
+)
+
+func (f ResampleFilter) String() string {
+	switch f {
+	case ResampleFilter_Nearest:
+		return "ResampleFilter_Nearest"
+	case ResampleFilter_Average:
+		return "ResampleFilter_Average"
+	case ResampleFilter_Lanczos:
+		return "ResampleFilter_Lanczos"
+	}
+	return fmt.Sprintf("ResampleFilter(%d)", int(f))
+}
+
+// makeOverviews returns the overviews of m requested by o, each one half
+// the size (rounded up) of the previous one.
+func makeOverviews(m image.Image, o *Options) (overviews []image.Image) {
+	if o == nil {
+		return
+	}
+	for i := 0; i < o.Overviews; i++ {
+		if b := m.Bounds(); b.Dx() <= 1 && b.Dy() <= 1 {
+			break
+		}
+		m = halveImage(m, o.OverviewFilter)
+		overviews = append(overviews, m)
+	}
+	return
+}
+
+// overviewOptions returns the options of the overview level, counted from
+// 1, of an image encoded with o. The overviews are not georeferenced, and
+// their resolution is halved at each level as they cover the same area
+// with fewer pixels.
+func overviewOptions(o *Options, level int) *Options {
+	v := *o
+	v.EntryMap = make(map[TagType]*IFDEntry, len(o.EntryMap))
+	for tag, entry := range o.EntryMap {
+		if !geoTags[tag] {
+			v.EntryMap[tag] = entry
+		}
+	}
+	x, ok := o.TagGetter().GetXResolution()
+	if !ok || x[1] == 0 {
+		x = [2]int64{72, 1}
+	}
+	y, ok := o.TagGetter().GetYResolution()
+	if !ok || y[1] == 0 {
+		y = [2]int64{72, 1}
+	}
+	for i := 0; i < level; i++ {
+		x, y = halveRational(x), halveRational(y)
+	}
+	setter := v.TagSetter()
+	setter.SetXResolution(x)
+	setter.SetYResolution(y)
+	return &v
+}
+
+// halveRational returns r/2, keeping it within 32 bits.
+func halveRational(r [2]int64) [2]int64 {
+	switch {
+	case r[0]%2 == 0:
+		r[0] /= 2
+	case r[1] <= math.MaxUint32/2:
+		r[1] *= 2
+	default:
+		r[0] /= 2
+	}
+	return r
+}
+
+// halveImage returns m downsampled to half its size, rounded up.
+// Paletted images are always downsampled with ResampleFilter_Nearest.
+// Images of other types than the ones the encoder writes natively are
+// converted to *image.RGBA.
+func halveImage(m image.Image, filter ResampleFilter) image.Image {
+	b := m.Bounds()
+	r := image.Rect(0, 0, (b.Dx()+1)/2, (b.Dy()+1)/2)
+
+	switch m := m.(type) {
+	case *image.Paletted:
+		dst := image.NewPaletted(r, m.Palette)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 1, 1, ResampleFilter_Nearest)
+		return dst
+	case *image.Gray:
+		dst := image.NewGray(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 1, 1, filter)
+		return dst
+	case *image.Gray16:
+		dst := image.NewGray16(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 1, 2, filter)
+		return dst
+	case *image.RGBA:
+		dst := image.NewRGBA(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 4, 1, filter)
+		return dst
+	case *image.NRGBA:
+		dst := image.NewNRGBA(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 4, 1, filter)
+		return dst
+	case *image.RGBA64:
+		dst := image.NewRGBA64(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 4, 2, filter)
+		return dst
+	case *image.NRGBA64:
+		dst := image.NewNRGBA64(r)
+		halvePix(dst.Pix, dst.Stride, m.Pix[m.PixOffset(b.Min.X, b.Min.Y):], m.Stride, b.Dx(), b.Dy(), 4, 2, filter)
+		return dst
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), m, b.Min, draw.Src)
+	return halveImage(rgba, filter)
+}
+
+// halvePix downsamples the w x h pixels of src into dst, a pixel has spp
+// samples of bps bytes (big-endian, as in the image package).
+func halvePix(dst []byte, dstStride int, src []byte, srcStride, w, h, spp, bps int, filter ResampleFilter) {
+	dw, dh := (w+1)/2, (h+1)/2
+	xWeights := halveWeights(w, filter)
+	yWeights := halveWeights(h, filter)
+
+	sample := func(pix []byte, i int) float64 {
+		if bps == 2 {
+			return float64(uint16(pix[i*2])<<8 | uint16(pix[i*2+1]))
+		}
+		return float64(pix[i])
+	}
+	max := float64(int(1)<<uint(bps*8) - 1)
+
+	// Downsample the rows of src into tmp, then the columns of tmp into dst.
+	tmp := make([]float64, h*dw*spp)
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride:]
+		for x := 0; x < dw; x++ {
+			for c := 0; c < spp; c++ {
+				var v float64
+				for _, k := range xWeights[x] {
+					v += k.weight * sample(row, k.index*spp+c)
+				}
+				tmp[(y*dw+x)*spp+c] = v
+			}
+		}
+	}
+	for y := 0; y < dh; y++ {
+		row := dst[y*dstStride:]
+		for x := 0; x < dw; x++ {
+			for c := 0; c < spp; c++ {
+				var v float64
+				for _, k := range yWeights[y] {
+					v += k.weight * tmp[(k.index*dw+x)*spp+c]
+				}
+				v = math.Max(0, math.Min(max, math.Floor(v+0.5)))
+				if i := x*spp + c; bps == 2 {
+					row[i*2] = uint8(uint16(v) >> 8)
+					row[i*2+1] = uint8(v)
+				} else {
+					row[i] = uint8(v)
+				}
+			}
+		}
+	}
+}
+
+type resampleWeight struct {
+	index  int
+	weight float64
+}
+
+// halveWeights returns for each of the (n+1)/2 output positions the
+// normalized source weights of filter.
+func halveWeights(n int, filter ResampleFilter) (weights [][]resampleWeight) {
+	weights = make([][]resampleWeight, (n+1)/2)
+	for i := range weights {
+		var list []resampleWeight
+		switch filter {
+		case ResampleFilter_Average:
+			list = append(list, resampleWeight{2 * i, 1})
+			if 2*i+1 < n {
+				list = append(list, resampleWeight{2*i + 1, 1})
+			}
+		case ResampleFilter_Lanczos:
+			// The output pixel i is centered between source pixels 2i and
+			// 2i+1, the kernel is stretched by the scale factor of 2.
+			center := float64(2*i) + 0.5
+			for j := 2*i - 5; j <= 2*i+6; j++ {
+				if j < 0 || j >= n {
+					continue
+				}
+				if v := lanczos3((float64(j) - center) / 2); v != 0 {
+					list = append(list, resampleWeight{j, v})
+				}
+			}
+		default:
+			list = append(list, resampleWeight{2 * i, 1})
+		}
+
+		var sum float64
+		for _, v := range list {
+			sum += v.weight
+		}
+		for k := range list {
+			list[k].weight /= sum
+		}
+		weights[i] = list
+	}
+	return
+}
+
+func lanczos3(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	if x <= -3 || x >= 3 {
+		return 0
+	}
+	x *= math.Pi
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}
@@ -7,9 +7,11 @@
 package tiff
 
 import (
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"sync"
 )
 
 var (
@@ -35,14 +37,75 @@ type seekioReader struct {
 	buf []byte
 	off int
 	err error
+
+	// size is the size of rs, and mu serializes the Seek and Read calls
+	// of ReadAt on rs when it is not an io.ReaderAt.
+	size int64
+	mu   sync.Mutex
 }
 
 func openSeekioReader(r io.Reader, maxBufferSize int) *seekioReader {
 	if rs, ok := r.(io.ReadSeeker); ok {
-		return &seekioReader{rs: rs}
+		p := &seekioReader{rs: rs, size: -1}
+		if cur, err := rs.Seek(0, 1); err == nil {
+			if end, err := rs.Seek(0, 2); err == nil {
+				p.size = end
+			}
+			rs.Seek(cur, 0)
+		}
+		return p
 	}
 	data, err := ioutil.ReadAll(r)
-	return &seekioReader{r: r, buf: data, err: err}
+	return &seekioReader{r: r, buf: data, err: err, size: int64(len(data))}
+}
+
+// Size returns the size of the data, or -1 when it is unknown.
+func (p *seekioReader) Size() int64 {
+	return p.size
+}
+
+// ReadAt reads from the underlying reader without moving the offset used
+// by Read and Seek. It is safe to call ReadAt from several goroutines.
+func (p *seekioReader) ReadAt(data []byte, off int64) (n int, err error) {
+	if p.err != nil {
+		err = p.err
+		return
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("tiff: seekioReader.ReadAt, negative offset %d", off)
+	}
+	if p.rs == nil {
+		if off >= int64(len(p.buf)) {
+			return 0, io.EOF
+		}
+		if n = copy(data, p.buf[off:]); n < len(data) {
+			err = io.EOF
+		}
+		return
+	}
+	if ra, ok := p.rs.(io.ReaderAt); ok {
+		return ra.ReadAt(data, off)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cur, err := p.rs.Seek(0, 1)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if _, e := p.rs.Seek(cur, 0); err == nil {
+			err = e
+		}
+	}()
+	if _, err = p.rs.Seek(off, 0); err != nil {
+		return
+	}
+	n, err = io.ReadFull(p.rs, data)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return
 }
 
 func (p *seekioReader) Read(data []byte) (n int, err error) {
@@ -0,0 +1,249 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExifIFD holds the entries of the Exif IFD of an image, pointed to by its
+// ExifIFD tag. The accessors may be called on a nil *ExifIFD, they return
+// false.
+type ExifIFD struct {
+	EntryMap map[ExifIFD_TagType]*IFDEntry
+
+	// Interoperability holds the entries of the Interoperability IFD,
+	// pointed to by the InteroperabilityIFD tag of the Exif IFD.
+	Interoperability map[InteroperabilityIFD_TagType]*IFDEntry
+}
+
+// GPSIFD holds the entries of the GPS IFD of an image, pointed to by its
+// GPSIFD tag. The accessors may be called on a nil *GPSIFD, they return
+// false.
+type GPSIFD struct {
+	EntryMap map[GPSIFD_TagType]*IFDEntry
+}
+
+// readExifIFDs reads the Exif and GPS IFDs of ifd, if any. A pointer to an
+// invalid IFD is ignored, as the image data does not depend on it.
+func readExifIFDs(r io.ReadSeeker, ifd *IFD) {
+	if ifd == nil {
+		return
+	}
+	if v, ok := ifd.TagGetter().GetExifIFD(); ok && len(v) == 1 {
+		if sub, err := ReadIFD(r, ifd.Header, v[0]); err == nil && sub != nil {
+			ifd.Exif = &ExifIFD{EntryMap: make(map[ExifIFD_TagType]*IFDEntry)}
+			for tag, entry := range sub.EntryMap {
+				ifd.Exif.EntryMap[ExifIFD_TagType(tag)] = entry
+			}
+			if v, ok := sub.TagGetter().GetInteroperabilityIFD(); ok && len(v) == 1 {
+				if sub, err := ReadIFD(r, ifd.Header, v[0]); err == nil && sub != nil {
+					ifd.Exif.Interoperability = make(map[InteroperabilityIFD_TagType]*IFDEntry)
+					for tag, entry := range sub.EntryMap {
+						ifd.Exif.Interoperability[InteroperabilityIFD_TagType(tag)] = entry
+					}
+				}
+			}
+		}
+	}
+	if v, ok := ifd.TagGetter().GetGPSIFD(); ok && len(v) == 1 {
+		if sub, err := ReadIFD(r, ifd.Header, v[0]); err == nil && sub != nil {
+			ifd.GPS = &GPSIFD{EntryMap: make(map[GPSIFD_TagType]*IFDEntry)}
+			for tag, entry := range sub.EntryMap {
+				ifd.GPS.EntryMap[GPSIFD_TagType(tag)] = entry
+			}
+		}
+	}
+}
+
+// exifTimeLayout is the layout of the Exif and TIFF dates.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// parseExifTime parses an Exif date, and its optional fraction of seconds.
+// Exif dates have no time zone, they are returned in UTC.
+func parseExifTime(s, subsec string) (t time.Time, ok bool) {
+	t, err := time.Parse(exifTimeLayout, strings.TrimSpace(s))
+	if err != nil {
+		return
+	}
+	if subsec = strings.TrimSpace(subsec); subsec != "" {
+		if v, err := strconv.ParseFloat("0."+subsec, 64); err == nil {
+			t = t.Add(time.Duration(v * float64(time.Second)))
+		}
+	}
+	return t, true
+}
+
+func exifString(entry *IFDEntry) (value string, ok bool) {
+	if entry == nil || entry.DataType != DataType_ASCII {
+		return
+	}
+	return entry.GetString(), true
+}
+
+// exifFloats returns the values of entry. It fails when a rational has a
+// zero denominator.
+func exifFloats(entry *IFDEntry) (values []float64, ok bool) {
+	if entry == nil {
+		return
+	}
+	for _, v := range entry.GetRationals() {
+		if v[1] == 0 {
+			return
+		}
+	}
+	values = entry.GetFloats()
+	return values, len(values) != 0
+}
+
+func exifFloat(entry *IFDEntry) (value float64, ok bool) {
+	if v, _ := exifFloats(entry); len(v) == 1 {
+		return v[0], true
+	}
+	return
+}
+
+func exifInt(entry *IFDEntry) (value int64, ok bool) {
+	if entry == nil {
+		return
+	}
+	if v := entry.GetInts(); len(v) >= 1 {
+		return v[0], true
+	}
+	return
+}
+
+// entry returns the entry tag, or nil. p may be nil.
+func (p *ExifIFD) entry(tag ExifIFD_TagType) *IFDEntry {
+	if p == nil {
+		return nil
+	}
+	return p.EntryMap[tag]
+}
+
+// GetString returns the ASCII entry tag.
+func (p *ExifIFD) GetString(tag ExifIFD_TagType) (value string, ok bool) {
+	return exifString(p.entry(tag))
+}
+
+// DateTimeOriginal returns the date the image was taken.
+func (p *ExifIFD) DateTimeOriginal() (value time.Time, ok bool) {
+	s, ok := p.GetString(ExifIFD_TagType_DateTimeOriginal)
+	if !ok {
+		return
+	}
+	subsec, _ := p.GetString(ExifIFD_TagType_SubsecTimeOriginal)
+	return parseExifTime(s, subsec)
+}
+
+// DateTimeDigitized returns the date the image was stored as digital data.
+func (p *ExifIFD) DateTimeDigitized() (value time.Time, ok bool) {
+	s, ok := p.GetString(ExifIFD_TagType_DateTimeDigitized)
+	if !ok {
+		return
+	}
+	subsec, _ := p.GetString(ExifIFD_TagType_SubsecTimeDigitized)
+	return parseExifTime(s, subsec)
+}
+
+// ExposureTime returns the exposure time in seconds.
+func (p *ExifIFD) ExposureTime() (value float64, ok bool) {
+	return exifFloat(p.entry(ExifIFD_TagType_ExposureTime))
+}
+
+// FNumber returns the F number.
+func (p *ExifIFD) FNumber() (value float64, ok bool) {
+	return exifFloat(p.entry(ExifIFD_TagType_FNumber))
+}
+
+// FocalLength returns the focal length of the lens in millimeters.
+func (p *ExifIFD) FocalLength() (value float64, ok bool) {
+	return exifFloat(p.entry(ExifIFD_TagType_FocalLength))
+}
+
+// ISOSpeedRatings returns the ISO speed.
+func (p *ExifIFD) ISOSpeedRatings() (value int64, ok bool) {
+	return exifInt(p.entry(ExifIFD_TagType_ISOSpeedRatings))
+}
+
+// InteroperabilityIndex returns the Interoperability rule, such as "R98".
+func (p *ExifIFD) InteroperabilityIndex() (value string, ok bool) {
+	if p == nil {
+		return
+	}
+	return exifString(p.Interoperability[InteroperabilityIFD_TagType_InteroperabilityIndex])
+}
+
+// entry returns the entry tag, or nil. p may be nil.
+func (p *GPSIFD) entry(tag GPSIFD_TagType) *IFDEntry {
+	if p == nil {
+		return nil
+	}
+	return p.EntryMap[tag]
+}
+
+// GetString returns the ASCII entry tag.
+func (p *GPSIFD) GetString(tag GPSIFD_TagType) (value string, ok bool) {
+	return exifString(p.entry(tag))
+}
+
+// degrees converts the degrees, minutes and seconds of tag to degrees,
+// negative when ref holds the negative direction neg.
+func (p *GPSIFD) degrees(tag, ref GPSIFD_TagType, neg string) (value float64, ok bool) {
+	v, ok := exifFloats(p.entry(tag))
+	if !ok || len(v) > 3 {
+		return value, false
+	}
+	for i, scale := range []float64{1, 60, 3600}[:len(v)] {
+		value += v[i] / scale
+	}
+	if s, _ := p.GetString(ref); strings.EqualFold(strings.TrimSpace(s), neg) {
+		value = -value
+	}
+	return value, true
+}
+
+// Latitude returns the latitude in degrees, negative in the south.
+func (p *GPSIFD) Latitude() (value float64, ok bool) {
+	return p.degrees(GPSIFD_TagType_GPSLatitude, GPSIFD_TagType_GPSLatitudeRef, "S")
+}
+
+// Longitude returns the longitude in degrees, negative in the west.
+func (p *GPSIFD) Longitude() (value float64, ok bool) {
+	return p.degrees(GPSIFD_TagType_GPSLongitude, GPSIFD_TagType_GPSLongitudeRef, "W")
+}
+
+// Altitude returns the altitude in meters, negative below the sea level.
+func (p *GPSIFD) Altitude() (value float64, ok bool) {
+	if value, ok = exifFloat(p.entry(GPSIFD_TagType_GPSAltitude)); !ok {
+		return
+	}
+	if ref, _ := exifInt(p.entry(GPSIFD_TagType_GPSAltitudeRef)); ref == 1 {
+		value = -value
+	}
+	return
+}
+
+// Time returns the UTC time of the GPS fix, from GPSDateStamp and
+// GPSTimeStamp.
+func (p *GPSIFD) Time() (value time.Time, ok bool) {
+	date, ok := p.GetString(GPSIFD_TagType_GPSDateStamp)
+	if !ok {
+		return
+	}
+	v, _ := exifFloats(p.entry(GPSIFD_TagType_GPSTimeStamp))
+	if len(v) != 3 {
+		return value, false
+	}
+	day, err := time.Parse("2006:01:02", strings.TrimSpace(date))
+	if err != nil {
+		return value, false
+	}
+	seconds := v[0]*3600 + v[1]*60 + v[2]
+	return day.Add(time.Duration(seconds * float64(time.Second))), true
+}
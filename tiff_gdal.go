@@ -0,0 +1,293 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The roles of the band items GDAL handles itself.
+const (
+	GDALMetadataRole_Scale       = "scale"
+	GDALMetadataRole_Offset      = "offset"
+	GDALMetadataRole_Description = "description"
+	GDALMetadataRole_UnitType    = "unittype"
+)
+
+// GDALMetadataItem is an item of the GDAL_METADATA tag.
+type GDALMetadataItem struct {
+	Name  string
+	Value string
+
+	// Sample is the band of the item, counted from zero, or -1 for an
+	// item of the whole dataset.
+	Sample int
+
+	// Role is set for the band properties, such as GDALMetadataRole_Scale,
+	// and empty for the other items.
+	Role string
+
+	// Domain is the metadata domain of the item, empty for the default
+	// domain.
+	Domain string
+}
+
+// GDALMetadata is the decoded GDAL_METADATA tag: the metadata items of
+// the dataset and of its bands. The accessors may be called on a nil
+// *GDALMetadata, they return false.
+type GDALMetadata struct {
+	Items []GDALMetadataItem
+}
+
+// The XML of the GDAL_METADATA tag.
+type gdalMetadataXML struct {
+	XMLName xml.Name              `xml:"GDALMetadata"`
+	Items   []gdalMetadataItemXML `xml:"Item"`
+}
+
+type gdalMetadataItemXML struct {
+	Name   string `xml:"name,attr"`
+	Sample string `xml:"sample,attr,omitempty"`
+	Role   string `xml:"role,attr,omitempty"`
+	Domain string `xml:"domain,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+// GDALMetadata decodes the GDAL_METADATA tag of the image. It returns nil
+// and no error when the image has no such tag.
+func (p *IFD) GDALMetadata() (md *GDALMetadata, err error) {
+	entry, ok := p.EntryMap[TagType_GDAL_METADATA]
+	if !ok {
+		return
+	}
+	return parseGDALMetadata(entry.GetString())
+}
+
+func parseGDALMetadata(s string) (md *GDALMetadata, err error) {
+	var v gdalMetadataXML
+	if err = xml.Unmarshal([]byte(s), &v); err != nil {
+		err = fmt.Errorf("tiff: IFD.GDALMetadata, %v", err)
+		return
+	}
+	md = &GDALMetadata{}
+	for _, item := range v.Items {
+		sample := -1
+		if item.Sample != "" {
+			if sample, err = strconv.Atoi(item.Sample); err != nil || sample < 0 {
+				md, err = nil, fmt.Errorf("tiff: IFD.GDALMetadata, bad sample %q of %q", item.Sample, item.Name)
+				return
+			}
+		}
+		md.Items = append(md.Items, GDALMetadataItem{
+			Name:   item.Name,
+			Value:  item.Value,
+			Sample: sample,
+			Role:   item.Role,
+			Domain: item.Domain,
+		})
+	}
+	return
+}
+
+// String returns p as the XML of the GDAL_METADATA tag, or "" when p is
+// nil.
+func (p *GDALMetadata) String() string {
+	if p == nil {
+		return ""
+	}
+	var v gdalMetadataXML
+	for _, item := range p.Items {
+		var sample string
+		if item.Sample >= 0 {
+			sample = strconv.Itoa(item.Sample)
+		}
+		v.Items = append(v.Items, gdalMetadataItemXML{
+			Name:   item.Name,
+			Sample: sample,
+			Role:   item.Role,
+			Domain: item.Domain,
+			Value:  item.Value,
+		})
+	}
+	data, _ := xml.MarshalIndent(&v, "", "  ")
+	return string(data)
+}
+
+// items returns the items of the default domain of sample, with role.
+func (p *GDALMetadata) items(sample int, role string) map[string]string {
+	m := make(map[string]string)
+	if p == nil {
+		return m
+	}
+	for _, item := range p.Items {
+		if item.Sample == sample && item.Role == role && item.Domain == "" {
+			m[item.Name] = item.Value
+		}
+	}
+	return m
+}
+
+// role returns the value of the band property role of sample, in the
+// default domain.
+func (p *GDALMetadata) role(sample int, role string) (value string, ok bool) {
+	if p == nil {
+		return
+	}
+	for _, item := range p.Items {
+		if item.Sample == sample && item.Role == role && item.Domain == "" {
+			return item.Value, true
+		}
+	}
+	return
+}
+
+// Dataset returns the items of the whole dataset, in the default domain.
+func (p *GDALMetadata) Dataset() map[string]string {
+	return p.items(-1, "")
+}
+
+// Band returns the items of the band sample, in the default domain. The
+// band properties, such as the scale, are not included.
+func (p *GDALMetadata) Band(sample int) map[string]string {
+	return p.items(sample, "")
+}
+
+// Scale returns the scale of the band sample: the values of its samples
+// are sample*scale + offset.
+func (p *GDALMetadata) Scale(sample int) (value float64, ok bool) {
+	s, ok := p.role(sample, GDALMetadataRole_Scale)
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return value, err == nil
+}
+
+// Offset returns the offset of the band sample.
+func (p *GDALMetadata) Offset(sample int) (value float64, ok bool) {
+	s, ok := p.role(sample, GDALMetadataRole_Offset)
+	if !ok {
+		return
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return value, err == nil
+}
+
+// Description returns the description of the band sample.
+func (p *GDALMetadata) Description(sample int) (value string, ok bool) {
+	return p.role(sample, GDALMetadataRole_Description)
+}
+
+// UnitType returns the unit of the values of the band sample.
+func (p *GDALMetadata) UnitType(sample int) (value string, ok bool) {
+	return p.role(sample, GDALMetadataRole_UnitType)
+}
+
+// Set sets the item name of the band sample, or of the dataset if sample
+// is -1, in the default domain.
+func (p *GDALMetadata) Set(sample int, name, value string) {
+	p.set(GDALMetadataItem{Name: name, Value: value, Sample: sample})
+}
+
+// SetScale sets the scale of the band sample.
+func (p *GDALMetadata) SetScale(sample int, value float64) {
+	p.set(GDALMetadataItem{Name: "SCALE", Value: formatGDALFloat(value), Sample: sample, Role: GDALMetadataRole_Scale})
+}
+
+// SetOffset sets the offset of the band sample.
+func (p *GDALMetadata) SetOffset(sample int, value float64) {
+	p.set(GDALMetadataItem{Name: "OFFSET", Value: formatGDALFloat(value), Sample: sample, Role: GDALMetadataRole_Offset})
+}
+
+// SetDescription sets the description of the band sample.
+func (p *GDALMetadata) SetDescription(sample int, value string) {
+	p.set(GDALMetadataItem{Name: "DESCRIPTION", Value: value, Sample: sample, Role: GDALMetadataRole_Description})
+}
+
+// SetUnitType sets the unit of the values of the band sample.
+func (p *GDALMetadata) SetUnitType(sample int, value string) {
+	p.set(GDALMetadataItem{Name: "UNITTYPE", Value: value, Sample: sample, Role: GDALMetadataRole_UnitType})
+}
+
+// set replaces the item with the same name, sample, role and domain, or
+// appends item.
+func (p *GDALMetadata) set(item GDALMetadataItem) {
+	for i, v := range p.Items {
+		if v.Name == item.Name && v.Sample == item.Sample && v.Role == item.Role && v.Domain == item.Domain {
+			p.Items[i] = item
+			return
+		}
+	}
+	p.Items = append(p.Items, item)
+}
+
+// GDALNoData returns the value of the GDAL_NODATA tag: the value of the
+// samples which have no data.
+func (p *IFD) GDALNoData() (value float64, ok bool) {
+	entry, ok := p.EntryMap[TagType_GDAL_NODATA]
+	if !ok {
+		return
+	}
+	return parseGDALFloat(entry.GetString())
+}
+
+// parseGDALFloat parses a GDAL_NODATA value, including the infinities and
+// NaN written by the Microsoft C library, such as "-1.#INF".
+func parseGDALFloat(s string) (value float64, ok bool) {
+	s = strings.TrimSpace(s)
+	switch strings.ToUpper(strings.TrimLeft(s, "+-")) {
+	case "1.#INF":
+		value = math.Inf(1)
+		if strings.HasPrefix(s, "-") {
+			value = math.Inf(-1)
+		}
+		return value, true
+	case "1.#QNAN", "1.#IND", "1.#SNAN":
+		return math.NaN(), true
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	return value, err == nil
+}
+
+// formatGDALFloat formats v as GDAL does.
+func formatGDALFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// maskNoData sets the float samples of m equal to nodata to NaN.
+func maskNoData(m *MemPImage, nodata float64) {
+	if math.IsNaN(nodata) {
+		return
+	}
+	switch m.XDataType {
+	case reflect.Float32:
+		pix, nodata := PixSlice(m.XPix).Float32s(), float32(nodata)
+		for i, v := range pix {
+			if v == nodata {
+				pix[i] = float32(math.NaN())
+			}
+		}
+	case reflect.Float64:
+		pix := PixSlice(m.XPix).Float64s()
+		for i, v := range pix {
+			if v == nodata {
+				pix[i] = math.NaN()
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+)
+
+// GeoInfo georeferences an image: its coordinate system, described by the
+// GeoKey directory, and its place in the model space, given by either the
+// ModelPixelScale and ModelTiepoint tags or the ModelTransformation tag.
+type GeoInfo struct {
+	Keys *GeoKeyDirectory
+
+	// ModelPixelScale is the size of a pixel in model units: X, Y and Z.
+	ModelPixelScale []float64
+
+	// ModelTiepoint holds the tiepoints, 6 values each: the raster point
+	// I, J, K and the model point X, Y, Z.
+	ModelTiepoint []float64
+
+	// ModelTransformation is the 4x4 matrix, in row major order,
+	// transforming the raster points to model points.
+	ModelTransformation []float64
+}
+
+// GeoInfo returns the GeoTIFF tags of the image. It returns nil and no
+// error when the image has none of them.
+func (p *IFD) GeoInfo() (info *GeoInfo, err error) {
+	keys, err := p.GeoKeyDirectory()
+	if err != nil {
+		return
+	}
+	getter := p.TagGetter()
+	scale, _ := getter.GetModelPixelScaleTag()
+	tiepoint, _ := getter.GetModelTiepointTag()
+	transformation, _ := getter.GetModelTransformationTag()
+	if keys == nil && scale == nil && tiepoint == nil && transformation == nil {
+		return
+	}
+	info = &GeoInfo{
+		Keys:                keys,
+		ModelPixelScale:     scale,
+		ModelTiepoint:       tiepoint,
+		ModelTransformation: transformation,
+	}
+	return
+}
+
+// SetGeoTransform places the image in the model space with t, as returned
+// by IFD.GeoTransform. A north up transform is written as a pixel scale
+// and a tiepoint, any other one as a transformation matrix. Set the
+// GTRasterTypeGeoKey of p.Keys first, the tiepoint of a PixelIsPoint image
+// is the center of its first pixel.
+func (p *GeoInfo) SetGeoTransform(t GeoTransform) {
+	if v, _ := p.Keys.RasterType(); v == GeoKeyValue_RasterPixelIsPoint {
+		t[0] += 0.5*t[1] + 0.5*t[2]
+		t[3] += 0.5*t[4] + 0.5*t[5]
+	}
+	if t[2] == 0 && t[4] == 0 && t[1] > 0 && t[5] < 0 {
+		p.ModelPixelScale = []float64{t[1], -t[5], 0}
+		p.ModelTiepoint = []float64{0, 0, 0, t[0], t[3], 0}
+		p.ModelTransformation = nil
+		return
+	}
+	p.ModelPixelScale, p.ModelTiepoint = nil, nil
+	p.ModelTransformation = []float64{
+		t[1], t[2], 0, t[0],
+		t[4], t[5], 0, t[3],
+		0, 0, 0, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// entryMap returns the GeoTIFF tags of p.
+func (p *GeoInfo) entryMap() (entryMap map[TagType]*IFDEntry, err error) {
+	entryMap = make(map[TagType]*IFDEntry)
+	setter := &tifTagSetter{EntryMap: entryMap}
+	if p.Keys != nil {
+		var keys []int64
+		var doubles []float64
+		var ascii string
+		if keys, doubles, ascii, err = p.Keys.encode(); err != nil {
+			return
+		}
+		if !setter.SetGeoKeyDirectoryTag(keys) {
+			err = fmt.Errorf("tiff: Encode, bad %v", TagType_GeoKeyDirectoryTag)
+			return
+		}
+		if len(doubles) != 0 && !setter.SetGeoDoubleParamsTag(doubles) {
+			err = fmt.Errorf("tiff: Encode, bad %v", TagType_GeoDoubleParamsTag)
+			return
+		}
+		if ascii != "" && !setter.SetGeoAsciiParamsTag(ascii) {
+			err = fmt.Errorf("tiff: Encode, bad %v", TagType_GeoAsciiParamsTag)
+			return
+		}
+	}
+	if len(p.ModelPixelScale) != 0 && !setter.SetModelPixelScaleTag(p.ModelPixelScale) {
+		err = fmt.Errorf("tiff: Encode, bad %v", TagType_ModelPixelScaleTag)
+		return
+	}
+	if len(p.ModelTiepoint) != 0 && (len(p.ModelTiepoint)%6 != 0 || !setter.SetModelTiepointTag(p.ModelTiepoint)) {
+		err = fmt.Errorf("tiff: Encode, bad %v", TagType_ModelTiepointTag)
+		return
+	}
+	if len(p.ModelTransformation) != 0 && !setter.SetModelTransformationTag(p.ModelTransformation) {
+		err = fmt.Errorf("tiff: Encode, bad %v", TagType_ModelTransformationTag)
+		return
+	}
+	return
+}
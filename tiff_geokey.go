@@ -0,0 +1,387 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GeoTIFF keys, stored in the GeoKeyDirectoryTag (see doc/geotiff.pdf).
+type GeoKeyType uint16
+
+const (
+	_                                         GeoKeyType = 0    // Type, # comment
+	GeoKeyType_GTModelTypeGeoKey              GeoKeyType = 1024 // SHORT # Model coordinate system: projected, geographic or geocentric.
+	GeoKeyType_GTRasterTypeGeoKey             GeoKeyType = 1025 // SHORT # Raster space: PixelIsArea or PixelIsPoint.
+	GeoKeyType_GTCitationGeoKey               GeoKeyType = 1026 // ASCII
+	GeoKeyType_GeographicTypeGeoKey           GeoKeyType = 2048 // SHORT # EPSG code of the geographic coordinate system.
+	GeoKeyType_GeogCitationGeoKey             GeoKeyType = 2049 // ASCII
+	GeoKeyType_GeogGeodeticDatumGeoKey        GeoKeyType = 2050 // SHORT
+	GeoKeyType_GeogPrimeMeridianGeoKey        GeoKeyType = 2051 // SHORT
+	GeoKeyType_GeogLinearUnitsGeoKey          GeoKeyType = 2052 // SHORT
+	GeoKeyType_GeogLinearUnitSizeGeoKey       GeoKeyType = 2053 // DOUBLE
+	GeoKeyType_GeogAngularUnitsGeoKey         GeoKeyType = 2054 // SHORT
+	GeoKeyType_GeogAngularUnitSizeGeoKey      GeoKeyType = 2055 // DOUBLE
+	GeoKeyType_GeogEllipsoidGeoKey            GeoKeyType = 2056 // SHORT
+	GeoKeyType_GeogSemiMajorAxisGeoKey        GeoKeyType = 2057 // DOUBLE
+	GeoKeyType_GeogSemiMinorAxisGeoKey        GeoKeyType = 2058 // DOUBLE
+	GeoKeyType_GeogInvFlatteningGeoKey        GeoKeyType = 2059 // DOUBLE
+	GeoKeyType_GeogAzimuthUnitsGeoKey         GeoKeyType = 2060 // SHORT
+	GeoKeyType_GeogPrimeMeridianLongGeoKey    GeoKeyType = 2061 // DOUBLE
+	GeoKeyType_GeogTOWGS84GeoKey              GeoKeyType = 2062 // DOUBLE, 3 or 7 # GeoTIFF 1.1.
+	GeoKeyType_ProjectedCSTypeGeoKey          GeoKeyType = 3072 // SHORT # EPSG code of the projected coordinate system.
+	GeoKeyType_PCSCitationGeoKey              GeoKeyType = 3073 // ASCII
+	GeoKeyType_ProjectionGeoKey               GeoKeyType = 3074 // SHORT
+	GeoKeyType_ProjCoordTransGeoKey           GeoKeyType = 3075 // SHORT
+	GeoKeyType_ProjLinearUnitsGeoKey          GeoKeyType = 3076 // SHORT
+	GeoKeyType_ProjLinearUnitSizeGeoKey       GeoKeyType = 3077 // DOUBLE
+	GeoKeyType_ProjStdParallel1GeoKey         GeoKeyType = 3078 // DOUBLE
+	GeoKeyType_ProjStdParallel2GeoKey         GeoKeyType = 3079 // DOUBLE
+	GeoKeyType_ProjNatOriginLongGeoKey        GeoKeyType = 3080 // DOUBLE
+	GeoKeyType_ProjNatOriginLatGeoKey         GeoKeyType = 3081 // DOUBLE
+	GeoKeyType_ProjFalseEastingGeoKey         GeoKeyType = 3082 // DOUBLE
+	GeoKeyType_ProjFalseNorthingGeoKey        GeoKeyType = 3083 // DOUBLE
+	GeoKeyType_ProjFalseOriginLongGeoKey      GeoKeyType = 3084 // DOUBLE
+	GeoKeyType_ProjFalseOriginLatGeoKey       GeoKeyType = 3085 // DOUBLE
+	GeoKeyType_ProjFalseOriginEastingGeoKey   GeoKeyType = 3086 // DOUBLE
+	GeoKeyType_ProjFalseOriginNorthingGeoKey  GeoKeyType = 3087 // DOUBLE
+	GeoKeyType_ProjCenterLongGeoKey           GeoKeyType = 3088 // DOUBLE
+	GeoKeyType_ProjCenterLatGeoKey            GeoKeyType = 3089 // DOUBLE
+	GeoKeyType_ProjCenterEastingGeoKey        GeoKeyType = 3090 // DOUBLE
+	GeoKeyType_ProjCenterNorthingGeoKey       GeoKeyType = 3091 // DOUBLE
+	GeoKeyType_ProjScaleAtNatOriginGeoKey     GeoKeyType = 3092 // DOUBLE
+	GeoKeyType_ProjScaleAtCenterGeoKey        GeoKeyType = 3093 // DOUBLE
+	GeoKeyType_ProjAzimuthAngleGeoKey         GeoKeyType = 3094 // DOUBLE
+	GeoKeyType_ProjStraightVertPoleLongGeoKey GeoKeyType = 3095 // DOUBLE
+	GeoKeyType_ProjRectifiedGridAngleGeoKey   GeoKeyType = 3096 // DOUBLE # GeoTIFF 1.1.
+	GeoKeyType_VerticalCSTypeGeoKey           GeoKeyType = 4096 // SHORT
+	GeoKeyType_VerticalCitationGeoKey         GeoKeyType = 4097 // ASCII
+	GeoKeyType_VerticalDatumGeoKey            GeoKeyType = 4098 // SHORT
+	GeoKeyType_VerticalUnitsGeoKey            GeoKeyType = 4099 // SHORT
+)
+
+var _GeoKeyTypeTable = map[GeoKeyType]string{
+	GeoKeyType_GTModelTypeGeoKey:              `GeoKeyType_GTModelTypeGeoKey`,
+	GeoKeyType_GTRasterTypeGeoKey:             `GeoKeyType_GTRasterTypeGeoKey`,
+	GeoKeyType_GTCitationGeoKey:               `GeoKeyType_GTCitationGeoKey`,
+	GeoKeyType_GeographicTypeGeoKey:           `GeoKeyType_GeographicTypeGeoKey`,
+	GeoKeyType_GeogCitationGeoKey:             `GeoKeyType_GeogCitationGeoKey`,
+	GeoKeyType_GeogGeodeticDatumGeoKey:        `GeoKeyType_GeogGeodeticDatumGeoKey`,
+	GeoKeyType_GeogPrimeMeridianGeoKey:        `GeoKeyType_GeogPrimeMeridianGeoKey`,
+	GeoKeyType_GeogLinearUnitsGeoKey:          `GeoKeyType_GeogLinearUnitsGeoKey`,
+	GeoKeyType_GeogLinearUnitSizeGeoKey:       `GeoKeyType_GeogLinearUnitSizeGeoKey`,
+	GeoKeyType_GeogAngularUnitsGeoKey:         `GeoKeyType_GeogAngularUnitsGeoKey`,
+	GeoKeyType_GeogAngularUnitSizeGeoKey:      `GeoKeyType_GeogAngularUnitSizeGeoKey`,
+	GeoKeyType_GeogEllipsoidGeoKey:            `GeoKeyType_GeogEllipsoidGeoKey`,
+	GeoKeyType_GeogSemiMajorAxisGeoKey:        `GeoKeyType_GeogSemiMajorAxisGeoKey`,
+	GeoKeyType_GeogSemiMinorAxisGeoKey:        `GeoKeyType_GeogSemiMinorAxisGeoKey`,
+	GeoKeyType_GeogInvFlatteningGeoKey:        `GeoKeyType_GeogInvFlatteningGeoKey`,
+	GeoKeyType_GeogAzimuthUnitsGeoKey:         `GeoKeyType_GeogAzimuthUnitsGeoKey`,
+	GeoKeyType_GeogPrimeMeridianLongGeoKey:    `GeoKeyType_GeogPrimeMeridianLongGeoKey`,
+	GeoKeyType_GeogTOWGS84GeoKey:              `GeoKeyType_GeogTOWGS84GeoKey`,
+	GeoKeyType_ProjectedCSTypeGeoKey:          `GeoKeyType_ProjectedCSTypeGeoKey`,
+	GeoKeyType_PCSCitationGeoKey:              `GeoKeyType_PCSCitationGeoKey`,
+	GeoKeyType_ProjectionGeoKey:               `GeoKeyType_ProjectionGeoKey`,
+	GeoKeyType_ProjCoordTransGeoKey:           `GeoKeyType_ProjCoordTransGeoKey`,
+	GeoKeyType_ProjLinearUnitsGeoKey:          `GeoKeyType_ProjLinearUnitsGeoKey`,
+	GeoKeyType_ProjLinearUnitSizeGeoKey:       `GeoKeyType_ProjLinearUnitSizeGeoKey`,
+	GeoKeyType_ProjStdParallel1GeoKey:         `GeoKeyType_ProjStdParallel1GeoKey`,
+	GeoKeyType_ProjStdParallel2GeoKey:         `GeoKeyType_ProjStdParallel2GeoKey`,
+	GeoKeyType_ProjNatOriginLongGeoKey:        `GeoKeyType_ProjNatOriginLongGeoKey`,
+	GeoKeyType_ProjNatOriginLatGeoKey:         `GeoKeyType_ProjNatOriginLatGeoKey`,
+	GeoKeyType_ProjFalseEastingGeoKey:         `GeoKeyType_ProjFalseEastingGeoKey`,
+	GeoKeyType_ProjFalseNorthingGeoKey:        `GeoKeyType_ProjFalseNorthingGeoKey`,
+	GeoKeyType_ProjFalseOriginLongGeoKey:      `GeoKeyType_ProjFalseOriginLongGeoKey`,
+	GeoKeyType_ProjFalseOriginLatGeoKey:       `GeoKeyType_ProjFalseOriginLatGeoKey`,
+	GeoKeyType_ProjFalseOriginEastingGeoKey:   `GeoKeyType_ProjFalseOriginEastingGeoKey`,
+	GeoKeyType_ProjFalseOriginNorthingGeoKey:  `GeoKeyType_ProjFalseOriginNorthingGeoKey`,
+	GeoKeyType_ProjCenterLongGeoKey:           `GeoKeyType_ProjCenterLongGeoKey`,
+	GeoKeyType_ProjCenterLatGeoKey:            `GeoKeyType_ProjCenterLatGeoKey`,
+	GeoKeyType_ProjCenterEastingGeoKey:        `GeoKeyType_ProjCenterEastingGeoKey`,
+	GeoKeyType_ProjCenterNorthingGeoKey:       `GeoKeyType_ProjCenterNorthingGeoKey`,
+	GeoKeyType_ProjScaleAtNatOriginGeoKey:     `GeoKeyType_ProjScaleAtNatOriginGeoKey`,
+	GeoKeyType_ProjScaleAtCenterGeoKey:        `GeoKeyType_ProjScaleAtCenterGeoKey`,
+	GeoKeyType_ProjAzimuthAngleGeoKey:         `GeoKeyType_ProjAzimuthAngleGeoKey`,
+	GeoKeyType_ProjStraightVertPoleLongGeoKey: `GeoKeyType_ProjStraightVertPoleLongGeoKey`,
+	GeoKeyType_ProjRectifiedGridAngleGeoKey:   `GeoKeyType_ProjRectifiedGridAngleGeoKey`,
+	GeoKeyType_VerticalCSTypeGeoKey:           `GeoKeyType_VerticalCSTypeGeoKey`,
+	GeoKeyType_VerticalCitationGeoKey:         `GeoKeyType_VerticalCitationGeoKey`,
+	GeoKeyType_VerticalDatumGeoKey:            `GeoKeyType_VerticalDatumGeoKey`,
+	GeoKeyType_VerticalUnitsGeoKey:            `GeoKeyType_VerticalUnitsGeoKey`,
+}
+
+func (p GeoKeyType) String() string {
+	if name, ok := _GeoKeyTypeTable[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("GeoKeyType_Unknown(%d)", uint16(p))
+}
+
+type (
+	GeoKeyValue_ModelType  uint16
+	GeoKeyValue_RasterType uint16
+)
+
+const (
+	GeoKeyValue_ModelType_Projected  GeoKeyValue_ModelType  = 1     // Projection coordinate system.
+	GeoKeyValue_ModelType_Geographic GeoKeyValue_ModelType  = 2     // Geographic latitude-longitude system.
+	GeoKeyValue_ModelType_Geocentric GeoKeyValue_ModelType  = 3     // Geocentric (X,Y,Z) coordinate system.
+	GeoKeyValue_RasterPixelIsArea    GeoKeyValue_RasterType = 1     // A pixel covers an area, the tiepoints refer to its upper left corner.
+	GeoKeyValue_RasterPixelIsPoint   GeoKeyValue_RasterType = 2     // A pixel is a point sample, the tiepoints refer to its center.
+	GeoKeyValue_UserDefined                                 = 32767 // The key is defined by other keys, not by an EPSG code.
+)
+
+// The version of the GeoKey directory, which changes only if the
+// directory structure changes.
+const geoKeyDirectoryVersion = 1
+
+// A GeoKey is a key of a GeoKey directory. Depending on DataType, its value
+// is held in Ints (DataType_Short, stored in the directory itself), Floats
+// (DataType_Double, stored in the GeoDoubleParamsTag) or ASCII (stored in
+// the GeoAsciiParamsTag).
+type GeoKey struct {
+	ID       GeoKeyType
+	DataType DataType
+	Ints     []int64
+	Floats   []float64
+	ASCII    string
+}
+
+// GeoKeyDirectory is the decoded GeoKeyDirectoryTag of a GeoTIFF, with the
+// values of the keys stored in the GeoDoubleParamsTag and GeoAsciiParamsTag
+// resolved. The accessors may be called on a nil *GeoKeyDirectory, they
+// return false.
+type GeoKeyDirectory struct {
+	Version       int
+	Revision      int
+	MinorRevision int
+	KeyMap        map[GeoKeyType]*GeoKey
+}
+
+// GeoKeyDirectory decodes the GeoKey directory of the image. It returns nil
+// and no error when the image has no GeoKeyDirectoryTag.
+func (p *IFD) GeoKeyDirectory() (dir *GeoKeyDirectory, err error) {
+	getter := p.TagGetter()
+	keys, ok := getter.GetGeoKeyDirectoryTag()
+	if !ok {
+		return
+	}
+	doubles, _ := getter.GetGeoDoubleParamsTag()
+	ascii, _ := getter.GetGeoAsciiParamsTag()
+	return parseGeoKeyDirectory(keys, doubles, ascii)
+}
+
+// parseGeoKeyDirectory decodes the GeoKeyDirectoryTag keys. Every key is
+// described by 4 values: its ID, the tag holding its value (zero when the
+// value is the fourth field), its count and its offset in that tag.
+func parseGeoKeyDirectory(keys []int64, doubles []float64, ascii string) (dir *GeoKeyDirectory, err error) {
+	if len(keys) < 4 {
+		err = fmt.Errorf("tiff: IFD.GeoKeyDirectory, bad header")
+		return
+	}
+	if keys[0] != geoKeyDirectoryVersion {
+		err = fmt.Errorf("tiff: IFD.GeoKeyDirectory, unsupported version %d", keys[0])
+		return
+	}
+	n := int(keys[3])
+	if len(keys) < 4+4*n {
+		err = fmt.Errorf("tiff: IFD.GeoKeyDirectory, %d keys, want %d", (len(keys)-4)/4, n)
+		return
+	}
+
+	dir = &GeoKeyDirectory{
+		Version:       int(keys[0]),
+		Revision:      int(keys[1]),
+		MinorRevision: int(keys[2]),
+		KeyMap:        make(map[GeoKeyType]*GeoKey, n),
+	}
+	for i := 0; i < n; i++ {
+		v := keys[4+4*i : 8+4*i]
+		key := &GeoKey{ID: GeoKeyType(v[0])}
+		location, count, offset := TagType(v[1]), int(v[2]), int(v[3])
+		switch {
+		case location == 0:
+			key.DataType, key.Ints = DataType_Short, []int64{int64(offset)}
+		case location == TagType_GeoKeyDirectoryTag && offset >= 0 && offset+count <= len(keys):
+			key.DataType, key.Ints = DataType_Short, append([]int64(nil), keys[offset:offset+count]...)
+		case location == TagType_GeoDoubleParamsTag && offset >= 0 && offset+count <= len(doubles):
+			key.DataType, key.Floats = DataType_Double, append([]float64(nil), doubles[offset:offset+count]...)
+		case location == TagType_GeoAsciiParamsTag && offset >= 0 && offset+count <= len(ascii):
+			// The strings end with a '|', replacing their NUL.
+			s := ascii[offset : offset+count]
+			key.DataType, key.ASCII = DataType_ASCII, strings.TrimRight(s, "|\x00")
+		default:
+			dir, err = nil, fmt.Errorf("tiff: IFD.GeoKeyDirectory, %v: bad value location %d[%d:%d]", key.ID, location, offset, offset+count)
+			return
+		}
+		dir.KeyMap[key.ID] = key
+	}
+	return
+}
+
+// key returns the key id, or nil. p may be nil.
+func (p *GeoKeyDirectory) key(id GeoKeyType) *GeoKey {
+	if p == nil {
+		return nil
+	}
+	return p.KeyMap[id]
+}
+
+// GetInt returns the SHORT value of the key id.
+func (p *GeoKeyDirectory) GetInt(id GeoKeyType) (value int64, ok bool) {
+	if key := p.key(id); key != nil && len(key.Ints) == 1 {
+		return key.Ints[0], true
+	}
+	return
+}
+
+// GetFloat returns the DOUBLE value of the key id.
+func (p *GeoKeyDirectory) GetFloat(id GeoKeyType) (value float64, ok bool) {
+	if key := p.key(id); key != nil && len(key.Floats) == 1 {
+		return key.Floats[0], true
+	}
+	return
+}
+
+// GetFloats returns the DOUBLE values of the key id.
+func (p *GeoKeyDirectory) GetFloats(id GeoKeyType) (value []float64, ok bool) {
+	if key := p.key(id); key != nil && key.DataType == DataType_Double {
+		return key.Floats, true
+	}
+	return
+}
+
+// GetString returns the ASCII value of the key id.
+func (p *GeoKeyDirectory) GetString(id GeoKeyType) (value string, ok bool) {
+	if key := p.key(id); key != nil && key.DataType == DataType_ASCII {
+		return key.ASCII, true
+	}
+	return
+}
+
+// ModelType returns the GTModelTypeGeoKey.
+func (p *GeoKeyDirectory) ModelType() (value GeoKeyValue_ModelType, ok bool) {
+	v, ok := p.GetInt(GeoKeyType_GTModelTypeGeoKey)
+	return GeoKeyValue_ModelType(v), ok
+}
+
+// RasterType returns the GTRasterTypeGeoKey.
+func (p *GeoKeyDirectory) RasterType() (value GeoKeyValue_RasterType, ok bool) {
+	v, ok := p.GetInt(GeoKeyType_GTRasterTypeGeoKey)
+	return GeoKeyValue_RasterType(v), ok
+}
+
+// Citation returns the GTCitationGeoKey.
+func (p *GeoKeyDirectory) Citation() (value string, ok bool) {
+	return p.GetString(GeoKeyType_GTCitationGeoKey)
+}
+
+// ProjectedCSType returns the EPSG code of the projected coordinate system,
+// or GeoKeyValue_UserDefined when it is described by the other keys.
+func (p *GeoKeyDirectory) ProjectedCSType() (value int, ok bool) {
+	v, ok := p.GetInt(GeoKeyType_ProjectedCSTypeGeoKey)
+	return int(v), ok
+}
+
+// GeographicType returns the EPSG code of the geographic coordinate system,
+// or GeoKeyValue_UserDefined when it is described by the other keys.
+func (p *GeoKeyDirectory) GeographicType() (value int, ok bool) {
+	v, ok := p.GetInt(GeoKeyType_GeographicTypeGeoKey)
+	return int(v), ok
+}
+
+// VerticalCSType returns the EPSG code of the vertical coordinate system.
+func (p *GeoKeyDirectory) VerticalCSType() (value int, ok bool) {
+	v, ok := p.GetInt(GeoKeyType_VerticalCSTypeGeoKey)
+	return int(v), ok
+}
+
+// SetInts sets the SHORT values of the key id.
+func (p *GeoKeyDirectory) SetInts(id GeoKeyType, value ...int64) {
+	p.setKey(&GeoKey{ID: id, DataType: DataType_Short, Ints: value})
+}
+
+// SetFloats sets the DOUBLE values of the key id.
+func (p *GeoKeyDirectory) SetFloats(id GeoKeyType, value ...float64) {
+	p.setKey(&GeoKey{ID: id, DataType: DataType_Double, Floats: value})
+}
+
+// SetString sets the ASCII value of the key id.
+func (p *GeoKeyDirectory) SetString(id GeoKeyType, value string) {
+	p.setKey(&GeoKey{ID: id, DataType: DataType_ASCII, ASCII: value})
+}
+
+func (p *GeoKeyDirectory) setKey(key *GeoKey) {
+	if p.KeyMap == nil {
+		p.KeyMap = make(map[GeoKeyType]*GeoKey)
+	}
+	p.KeyMap[key.ID] = key
+}
+
+// encode returns the values of the GeoKeyDirectoryTag, GeoDoubleParamsTag
+// and GeoAsciiParamsTag holding the keys, in ascending order. A SHORT key
+// with a single value is stored in the directory entry itself, and the
+// other SHORT values after the entries.
+func (p *GeoKeyDirectory) encode() (keys []int64, doubles []float64, ascii string, err error) {
+	var ids []int
+	for id := range p.KeyMap {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+
+	version, revision := p.Version, p.Revision
+	if version == 0 {
+		version, revision = geoKeyDirectoryVersion, 1
+	}
+	keys = []int64{int64(version), int64(revision), int64(p.MinorRevision), int64(len(ids))}
+
+	var shorts []int64
+	for _, id := range ids {
+		key := p.KeyMap[GeoKeyType(id)]
+		var location TagType
+		var count, offset int
+		switch key.DataType {
+		case DataType_Short:
+			for _, v := range key.Ints {
+				if v < 0 || v > 0xffff {
+					err = fmt.Errorf("tiff: Encode, %v: value %d is not a SHORT", key.ID, v)
+					return
+				}
+			}
+			switch len(key.Ints) {
+			case 0:
+				err = fmt.Errorf("tiff: Encode, %v: no value", key.ID)
+				return
+			case 1:
+				count, offset = 1, int(key.Ints[0])
+			default:
+				location, count, offset = TagType_GeoKeyDirectoryTag, len(key.Ints), 4+4*len(ids)+len(shorts)
+				shorts = append(shorts, key.Ints...)
+			}
+		case DataType_Double:
+			location, count, offset = TagType_GeoDoubleParamsTag, len(key.Floats), len(doubles)
+			doubles = append(doubles, key.Floats...)
+		case DataType_ASCII:
+			if strings.ContainsAny(key.ASCII, "|\x00") {
+				err = fmt.Errorf("tiff: Encode, %v: %q holds a '|' or a NUL", key.ID, key.ASCII)
+				return
+			}
+			location, count, offset = TagType_GeoAsciiParamsTag, len(key.ASCII)+1, len(ascii)
+			ascii += key.ASCII + "|"
+		default:
+			err = fmt.Errorf("tiff: Encode, %v: unsupported data type %v", key.ID, key.DataType)
+			return
+		}
+		if count > 0xffff || offset > 0xffff {
+			err = fmt.Errorf("tiff: Encode, %v: too many values", key.ID)
+			return
+		}
+		keys = append(keys, int64(id), int64(location), int64(count), int64(offset))
+	}
+	keys = append(keys, shorts...)
+	return
+}
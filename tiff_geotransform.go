@@ -0,0 +1,77 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+// GeoTransform is the affine transform from the raster space of an image
+// to its model space, with the coefficients in the order used by GDAL:
+//
+//	x = t[0] + col*t[1] + row*t[2]
+//	y = t[3] + col*t[4] + row*t[5]
+//
+// (col, row) = (0, 0) is the upper left corner of the upper left pixel, and
+// (0.5, 0.5) its center, whatever the GTRasterTypeGeoKey of the image.
+type GeoTransform [6]float64
+
+// GeoTransform returns the transform from the raster space of the image to
+// its model space. It is given by the ModelTransformationTag, or else by
+// the ModelPixelScaleTag and a single ModelTiepointTag. The transform is
+// shifted by half a pixel when the image has the RasterPixelIsPoint raster
+// type, whose tiepoints refer to the pixel centers.
+//
+// ok is false for an image which is not georeferenced, or which is only
+// georeferenced by several tiepoints (ground control points).
+func (p *IFD) GeoTransform() (t GeoTransform, ok bool) {
+	getter := p.TagGetter()
+	if m, _ := getter.GetModelTransformationTag(); len(m) == 16 {
+		t = GeoTransform{m[3], m[0], m[1], m[7], m[4], m[5]}
+	} else {
+		scale, _ := getter.GetModelPixelScaleTag()
+		tiepoint, _ := getter.GetModelTiepointTag()
+		if len(scale) < 2 || len(tiepoint) != 6 {
+			return
+		}
+		i, j, x, y := tiepoint[0], tiepoint[1], tiepoint[3], tiepoint[4]
+		t = GeoTransform{x - i*scale[0], scale[0], 0, y + j*scale[1], 0, -scale[1]}
+	}
+
+	dir, _ := p.GeoKeyDirectory()
+	if v, _ := dir.RasterType(); v == GeoKeyValue_RasterPixelIsPoint {
+		t[0] -= 0.5*t[1] + 0.5*t[2]
+		t[3] -= 0.5*t[4] + 0.5*t[5]
+	}
+	return t, true
+}
+
+// PixelToModel returns the model coordinates of the raster point (col, row).
+func (t GeoTransform) PixelToModel(col, row float64) (x, y float64) {
+	x = t[0] + col*t[1] + row*t[2]
+	y = t[3] + col*t[4] + row*t[5]
+	return
+}
+
+// ModelToPixel returns the raster point of the model coordinates (x, y).
+// ok is false if t is not invertible.
+func (t GeoTransform) ModelToPixel(x, y float64) (col, row float64, ok bool) {
+	inv, ok := t.Invert()
+	if !ok {
+		return
+	}
+	col, row = inv.PixelToModel(x, y)
+	return col, row, true
+}
+
+// Invert returns the transform from the model space to the raster space.
+// ok is false if t is not invertible.
+func (t GeoTransform) Invert() (inv GeoTransform, ok bool) {
+	det := t[1]*t[5] - t[2]*t[4]
+	if det == 0 {
+		return
+	}
+	inv[1], inv[2] = t[5]/det, -t[2]/det
+	inv[4], inv[5] = -t[4]/det, t[1]/det
+	inv[0] = -(inv[1]*t[0] + inv[2]*t[3])
+	inv[3] = -(inv[4]*t[0] + inv[5]*t[3])
+	return inv, true
+}
@@ -17,6 +17,11 @@ type IFD struct {
 	EntryMap map[TagType]*IFDEntry
 	ThisIFD  int64
 	NextIFD  int64
+
+	// Exif and GPS are the IFDs pointed to by the ExifIFD and GPSIFD tags,
+	// read by OpenReader. They are nil when the image has no such tag.
+	Exif *ExifIFD
+	GPS  *GPSIFD
 }
 
 func NewIFD(hdr *Header, width, height, depth, channels int, kind reflect.Kind) (ifd *IFD) {
@@ -79,6 +84,9 @@ func readIFD(r io.ReadSeeker, h *Header, offset int64) (p *IFD, err error) {
 
 	// read IFDEntry Data
 	for _, entry := range p.EntryMap {
+		if entry.lazy = newLazyEntryData(r, entry); entry.lazy != nil {
+			continue
+		}
 		if entry.Data, err = readIFDEntryData(r, entry); err != nil {
 			return
 		}
@@ -120,6 +128,9 @@ func readIFD8(r io.ReadSeeker, h *Header, offset int64) (p *IFD, err error) {
 
 	// read IFDEntry8 Data
 	for _, entry := range p.EntryMap {
+		if entry.lazy = newLazyEntryData(r, entry); entry.lazy != nil {
+			continue
+		}
 		if entry.Data, err = readIFDEntry8Data(r, entry); err != nil {
 			return
 		}
@@ -128,6 +139,25 @@ func readIFD8(r io.ReadSeeker, h *Header, offset int64) (p *IFD, err error) {
 	return
 }
 
+// lazyEntrySize is the size above which the values of an entry read from
+// OpenReaderAt, such as the tile offsets of a large image, an ICC profile or
+// an XMP packet, are only read when they are used.
+const lazyEntrySize = 4 << 10
+
+// newLazyEntryData returns the loader of the values of entry when they are
+// read lazily from r, or nil when they are read now.
+func newLazyEntryData(r io.ReadSeeker, entry *IFDEntry) *lazyEntryData {
+	ra, ok := r.(*readerAtSeeker)
+	size := entry.DataType.ByteSize() * entry.Count
+	if !ok || size <= lazyEntrySize {
+		return nil
+	}
+	if entry.Offset < int64(entry.Header.HeadSize()) || entry.Offset+int64(size) > ra.Size() {
+		return nil
+	}
+	return &lazyEntryData{r: ra, offset: entry.Offset, size: size}
+}
+
 func readIFDEntry(r io.ReadSeeker, h *Header) (entry *IFDEntry, err error) {
 	var entryTag TagType
 	if err = binary.Read(r, h.ByteOrder, &entryTag); err != nil {
@@ -5,11 +5,14 @@
 package tiff
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
 	"io"
+	"io/ioutil"
 )
 
 func (p *IFD) BlocksAcross() int {
@@ -125,25 +128,70 @@ func (p *IFD) BlockCount(col, row int) int64 {
 	}
 }
 
-func (p *IFD) DecodeBlock(r io.ReadSeeker, col, row int, dst image.Image) (err error) {
+// DecodeBlock decodes the block at col/row and stores the pixels which are
+// inside the bounds of dst. dst must be created by newImageWithIFD with the
+// same opt.
+func (p *IFD) DecodeBlock(r io.ReadSeeker, col, row int, dst image.Image, opt *DecodeOptions) (err error) {
+	var data []byte
+	if data, err = p.readBlock(r, col, row); err != nil {
+		return
+	}
+	return p.decodeBlockData(data, col, row, dst, opt)
+}
+
+// readBlock returns the stored bytes of the block at col/row. It uses a
+// single positional read when r is also an io.ReaderAt. A block which is
+// cut short by the end of the file is returned as is.
+func (p *IFD) readBlock(r io.ReadSeeker, col, row int) (data []byte, err error) {
 	blocksAcross, blocksDown := p.BlocksAcross(), p.BlocksDown()
 	if col < 0 || row < 0 || col >= blocksAcross || row >= blocksDown {
 		err = fmt.Errorf("tiff: IFD.DecodeBlock, bad col/row = %d/%d", col, row)
 		return
 	}
 
-	bounds := p.BlockBounds(col, row)
 	offset := p.BlockOffset(col, row)
 	count := p.BlockCount(col, row)
+	if offset < 0 || count < 0 {
+		err = fmt.Errorf("tiff: IFD.DecodeBlock, bad block offset/count = %d/%d", offset, count)
+		return
+	}
+
+	if ra, ok := r.(io.ReaderAt); ok {
+		if sz, ok := r.(interface{ Size() int64 }); ok && sz.Size() >= 0 && offset+count > sz.Size() {
+			if count = sz.Size() - offset; count < 0 {
+				count = 0
+			}
+		}
+		var n int
+		data = make([]byte, count)
+		if n, err = ra.ReadAt(data, offset); err == io.EOF {
+			err = nil
+		}
+		data = data[:n]
+		return
+	}
 
 	if _, err = r.Seek(offset, 0); err != nil {
 		return
 	}
-	limitReader := io.LimitReader(r, count)
+	data, err = ioutil.ReadAll(io.LimitReader(r, count))
+	return
+}
+
+// decodeBlockData decodes data, the stored bytes of the block at col/row,
+// into dst.
+func (p *IFD) decodeBlockData(data []byte, col, row int, dst image.Image, opt *DecodeOptions) (err error) {
+	bounds := p.BlockBounds(col, row)
+
+	// FillOrder=2 stores the bits of each byte LSB-first, reverse them
+	// before handing the block to the codec, as libtiff does.
+	if fillOrder, _ := p.TagGetter().GetFillOrder(); TagValue_FillOrderType(fillOrder) == TagValue_FillOrderType_LSB2MSB {
+		data = append([]byte(nil), data...)
+		reverseBits(data)
+	}
 
-	var data []byte
 	var img image.Image
-	if data, img, err = p.Compression().Decode(limitReader, bounds.Dx(), bounds.Dy(), p); err != nil {
+	if data, img, err = p.Compression().Decode(bytes.NewReader(data), bounds.Dx(), bounds.Dy(), p); err != nil {
 		return
 	}
 
@@ -167,7 +215,7 @@ func (p *IFD) DecodeBlock(r io.ReadSeeker, col, row int, dst image.Image) (err e
 		}
 	}
 
-	err = p.decodeBlock(data, dst, bounds)
+	err = p.decodeBlock(data, dst, bounds, opt)
 	return
 }
 
@@ -212,197 +260,280 @@ func (p *IFD) decodePredictor(data []byte, r image.Rectangle) (out []byte, err e
 	return
 }
 
-func (p *IFD) decodeBlock(buf []byte, dst image.Image, r image.Rectangle) (err error) {
-	xmin, ymin := r.Min.X, r.Min.Y
-	xmax, ymax := r.Max.X, r.Max.Y
+// decodeBlock stores the uncompressed block buf with bounds r into dst.
+// Only the pixels inside both dst and the image bounds are stored.
+func (p *IFD) decodeBlock(buf []byte, dst image.Image, r image.Rectangle, opt *DecodeOptions) (err error) {
+	rect := r.Intersect(dst.Bounds()).Intersect(p.Bounds())
+	if rect.Empty() {
+		return
+	}
+
+	if opt != nil && opt.KeepRawSamples {
+		m, ok := dst.(*MemPImage)
+		if !ok {
+			err = fmt.Errorf("tiff: IFD.decodeBlock, raw samples need a *MemPImage, got %T", dst)
+			return
+		}
+		return p.decodeBlockRaw(buf, m, r, rect)
+	}
+
+	var (
+		bpp = p.Depth()
+		spp = p.Channels()
 
-	rMaxX := minInt(xmax, dst.Bounds().Max.X)
-	rMaxY := minInt(ymax, dst.Bounds().Max.Y)
+		// rowSize is the number of bytes of one block row in buf,
+		// rows are padded to whole bytes.
+		rowSize = (r.Dx()*spp*bpp + 7) / 8
+	)
 
-	b := p.Bounds()
-	rMaxX = minInt(rMaxX, b.Max.X)
-	rMaxY = minInt(rMaxY, b.Max.Y)
+	// Fax data is decoded into one byte per pixel.
+	isFax := false
+	if x := p.Compression(); bpp == 1 && (x == TagValue_CompressionType_G3 || x == TagValue_CompressionType_G4) {
+		isFax = true
+		rowSize = r.Dx()
+	}
+
+	// rowAt returns the block row that holds the pixels of line y.
+	rowAt := func(y int) (row []byte, err error) {
+		off := (y - r.Min.Y) * rowSize
+		if off+rowSize > len(buf) {
+			err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
+			return
+		}
+		return buf[off : off+rowSize], nil
+	}
 
 	switch p.ImageType() {
 	case ImageType_Gray, ImageType_GrayInvert, ImageType_Bilevel, ImageType_BilevelInvert:
-		if x, bpp := p.Compression(), p.Depth(); bpp == 1 && (x == TagValue_CompressionType_G3 || x == TagValue_CompressionType_G4) {
+		if isFax {
 			img := dst.(*image.Gray)
-			for y := ymin; y < rMaxY; y++ {
-				min := img.PixOffset(xmin, y)
-				max := img.PixOffset(rMaxX, y)
-				off := (y - ymin) * (xmax - xmin) * 1
-				for i := min; i < max; i++ {
-
-					// Inverse pixel data when bilevel.
-					if p.ImageType() == ImageType_Bilevel {
-						img.Pix[i+0] = 0xff - buf[off+0]
-					} else {
-						img.Pix[i+0] = buf[off+0]
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				row, err := rowAt(y)
+				if err != nil {
+					return err
+				}
+				min := img.PixOffset(rect.Min.X, y)
+				max := img.PixOffset(rect.Max.X, y)
+				src := row[rect.Min.X-r.Min.X:]
+
+				// Inverse pixel data when bilevel.
+				if p.ImageType() == ImageType_Bilevel {
+					for i := min; i < max; i++ {
+						img.Pix[i] = 0xff - src[i-min]
 					}
-
-					off++
+				} else {
+					copy(img.Pix[min:max], src)
 				}
 			}
 			return
 		}
 
-		if p.Depth() == 16 {
-			var off int
-			img := dst.(*image.Gray16)
-			for y := ymin; y < rMaxY; y++ {
-				for x := xmin; x < rMaxX; x++ {
-					if off+2 > len(buf) {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
-					}
-					v := p.Header.ByteOrder.Uint16(buf[off : off+2])
+		if bpp == 16 {
+			gray16, _ := dst.(*image.Gray16)
+			drawImage := dst.(draw.Image)
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				row, err := rowAt(y)
+				if err != nil {
+					return err
+				}
+				off := (rect.Min.X - r.Min.X) * 2
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					v := p.Header.ByteOrder.Uint16(row[off : off+2])
 					off += 2
 					if p.ImageType() == ImageType_GrayInvert {
 						v = 0xffff - v
 					}
-					img.SetGray16(x, y, color.Gray16{v})
+					if gray16 != nil {
+						gray16.SetGray16(x, y, color.Gray16{v})
+					} else {
+						drawImage.Set(x, y, color.Gray{uint8(v >> 8)})
+					}
 				}
 			}
-		} else {
-			bpp := uint(p.Depth())
-			bitReader := newBitsReader(buf)
-			img := dst.(*image.Gray)
+			return
+		}
 
-			max := uint32((1 << uint(p.Depth())) - 1)
-			for y := ymin; y < rMaxY; y++ {
-				for x := xmin; x < rMaxX; x++ {
-					v, ok := bitReader.ReadBits(bpp)
-					if !ok {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
-					}
-					v = v * 0xff / max
-					if p.ImageType() == ImageType_GrayInvert {
-						v = 0xff - v
-					}
-					img.SetGray(x, y, color.Gray{uint8(v)})
+		img := dst.(*image.Gray)
+		max := uint32((1 << uint(bpp)) - 1)
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row, err := rowAt(y)
+			if err != nil {
+				return err
+			}
+			bitReader := newBitsReader(row)
+			bitReader.skipBits(uint((rect.Min.X - r.Min.X) * bpp))
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				v, ok := bitReader.ReadBits(uint(bpp))
+				if !ok {
+					return fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
 				}
-				bitReader.flushBits()
+				v = v * 0xff / max
+				if p.ImageType() == ImageType_GrayInvert {
+					v = 0xff - v
+				}
+				img.SetGray(x, y, color.Gray{uint8(v)})
 			}
 		}
 	case ImageType_Paletted:
-		bpp := uint(p.Depth())
-		bitReader := newBitsReader(buf)
-		img := dst.(*image.Paletted)
-		for y := ymin; y < rMaxY; y++ {
-			for x := xmin; x < rMaxX; x++ {
-				v, ok := bitReader.ReadBits(bpp)
+		paletted, _ := dst.(*image.Paletted)
+		drawImage := dst.(draw.Image)
+		palette := p.ColorMap()
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row, err := rowAt(y)
+			if err != nil {
+				return err
+			}
+			bitReader := newBitsReader(row)
+			bitReader.skipBits(uint((rect.Min.X - r.Min.X) * bpp))
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				v, ok := bitReader.ReadBits(uint(bpp))
 				if !ok {
-					err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-					return
+					return fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
+				}
+				if paletted != nil {
+					paletted.SetColorIndex(x, y, uint8(v))
+				} else if int(v) < len(palette) {
+					drawImage.Set(x, y, palette[v])
 				}
-				img.SetColorIndex(x, y, uint8(v))
 			}
-			bitReader.flushBits()
 		}
-	case ImageType_RGB:
-		if p.Depth() == 16 {
-			var off int
-			img := dst.(*image.RGBA64)
-			for y := ymin; y < rMaxY; y++ {
-				for x := xmin; x < rMaxX; x++ {
-					if off+6 > len(buf) {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
+	case ImageType_RGB, ImageType_NRGBA, ImageType_RGBA:
+		if bpp == 16 {
+			drawImage := dst.(draw.Image)
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				row, err := rowAt(y)
+				if err != nil {
+					return err
+				}
+				off := (rect.Min.X - r.Min.X) * spp * 2
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					r := p.Header.ByteOrder.Uint16(row[off+0 : off+2])
+					g := p.Header.ByteOrder.Uint16(row[off+2 : off+4])
+					b := p.Header.ByteOrder.Uint16(row[off+4 : off+6])
+					a := uint16(0xffff)
+					if spp == 4 {
+						a = p.Header.ByteOrder.Uint16(row[off+6 : off+8])
+					}
+					off += spp * 2
+					if p.ImageType() == ImageType_NRGBA {
+						drawImage.Set(x, y, color.NRGBA64{r, g, b, a})
+					} else {
+						drawImage.Set(x, y, color.RGBA64{r, g, b, a})
 					}
-					r := p.Header.ByteOrder.Uint16(buf[off+0 : off+2])
-					g := p.Header.ByteOrder.Uint16(buf[off+2 : off+4])
-					b := p.Header.ByteOrder.Uint16(buf[off+4 : off+6])
-					off += 6
-					img.SetRGBA64(x, y, color.RGBA64{r, g, b, 0xffff})
 				}
 			}
-		} else {
+			return
+		}
+
+		if p.ImageType() == ImageType_RGB {
 			img := dst.(*image.RGBA)
-			for y := ymin; y < rMaxY; y++ {
-				min := img.PixOffset(xmin, y)
-				max := img.PixOffset(rMaxX, y)
-				off := (y - ymin) * (xmax - xmin) * 3
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				row, err := rowAt(y)
+				if err != nil {
+					return err
+				}
+				min := img.PixOffset(rect.Min.X, y)
+				max := img.PixOffset(rect.Max.X, y)
+				off := (rect.Min.X - r.Min.X) * 3
 				for i := min; i < max; i += 4 {
-					if off+3 > len(buf) {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
-					}
-					img.Pix[i+0] = buf[off+0]
-					img.Pix[i+1] = buf[off+1]
-					img.Pix[i+2] = buf[off+2]
+					img.Pix[i+0] = row[off+0]
+					img.Pix[i+1] = row[off+1]
+					img.Pix[i+2] = row[off+2]
 					img.Pix[i+3] = 0xff
 					off += 3
 				}
 			}
+			return
 		}
-	case ImageType_NRGBA:
-		if p.Depth() == 16 {
-			var off int
-			img := dst.(*image.NRGBA64)
-			for y := ymin; y < rMaxY; y++ {
-				for x := xmin; x < rMaxX; x++ {
-					if off+8 > len(buf) {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
-					}
-					r := p.Header.ByteOrder.Uint16(buf[off+0 : off+2])
-					g := p.Header.ByteOrder.Uint16(buf[off+2 : off+4])
-					b := p.Header.ByteOrder.Uint16(buf[off+4 : off+6])
-					a := p.Header.ByteOrder.Uint16(buf[off+6 : off+8])
-					off += 8
-					img.SetNRGBA64(x, y, color.NRGBA64{r, g, b, a})
-				}
+
+		var pix []byte
+		var pixOffset func(x, y int) int
+		switch img := dst.(type) {
+		case *image.NRGBA:
+			pix, pixOffset = img.Pix, img.PixOffset
+		case *image.RGBA:
+			pix, pixOffset = img.Pix, img.PixOffset
+		default:
+			return fmt.Errorf("tiff: IFD.decodeBlock, bad destination image %T", dst)
+		}
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			row, err := rowAt(y)
+			if err != nil {
+				return err
 			}
-		} else {
-			img := dst.(*image.NRGBA)
-			for y := ymin; y < rMaxY; y++ {
-				min := img.PixOffset(xmin, y)
-				max := img.PixOffset(rMaxX, y)
-				i0, i1 := (y-ymin)*(xmax-xmin)*4, (y-ymin+1)*(xmax-xmin)*4
-				if i1 > len(buf) {
-					err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-					return
+			min := pixOffset(rect.Min.X, y)
+			max := pixOffset(rect.Max.X, y)
+			copy(pix[min:max], row[(rect.Min.X-r.Min.X)*4:])
+		}
+	default:
+		err = fmt.Errorf("tiff: IFD.decodeBlock, unknown imageType: %v", p.ImageType())
+		return
+	}
+
+	return
+}
+
+// decodeBlockRaw stores the samples of the uncompressed block buf in dst
+// without any conversion, except for the byte order and unpacking of
+// samples smaller than one byte.
+func (p *IFD) decodeBlockRaw(buf []byte, dst *MemPImage, r, rect image.Rectangle) (err error) {
+	var (
+		bpp = p.Depth()
+		spp = p.Channels()
+
+		rowSize = (r.Dx()*spp*bpp + 7) / 8
+	)
+
+	// Fax data is decoded into one byte per pixel, white is 0xff.
+	if x := p.Compression(); bpp == 1 && (x == TagValue_CompressionType_G3 || x == TagValue_CompressionType_G4) {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			off := (y-r.Min.Y)*r.Dx() + (rect.Min.X - r.Min.X)
+			if off+rect.Dx() > len(buf) {
+				return fmt.Errorf("tiff: IFD.decodeBlockRaw, not enough pixel data")
+			}
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				v := buf[off] >> 7
+				if p.ImageType() == ImageType_BilevelInvert {
+					v = 1 - v
 				}
-				copy(img.Pix[min:max], buf[i0:i1])
+				dst.XPix[dst.PixOffset(x, y)] = v
+				off++
 			}
 		}
-	case ImageType_RGBA:
-		if p.Depth() == 16 {
-			var off int
-			img := dst.(*image.RGBA64)
-			for y := ymin; y < rMaxY; y++ {
-				for x := xmin; x < rMaxX; x++ {
-					if off+8 > len(buf) {
-						err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-						return
-					}
-					r := p.Header.ByteOrder.Uint16(buf[off+0 : off+2])
-					g := p.Header.ByteOrder.Uint16(buf[off+2 : off+4])
-					b := p.Header.ByteOrder.Uint16(buf[off+4 : off+6])
-					a := p.Header.ByteOrder.Uint16(buf[off+6 : off+8])
-					off += 8
-					img.SetRGBA64(x, y, color.RGBA64{r, g, b, a})
-				}
+		return
+	}
+
+	if bpp < 8 {
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			off := (y - r.Min.Y) * rowSize
+			if off+rowSize > len(buf) {
+				return fmt.Errorf("tiff: IFD.decodeBlockRaw, not enough pixel data")
 			}
-		} else {
-			img := dst.(*image.RGBA)
-			for y := ymin; y < rMaxY; y++ {
-				min := img.PixOffset(xmin, y)
-				max := img.PixOffset(rMaxX, y)
-				i0, i1 := (y-ymin)*(xmax-xmin)*4, (y-ymin+1)*(xmax-xmin)*4
-				if i1 > len(buf) {
-					err = fmt.Errorf("tiff: IFD.decodeBlock, not enough pixel data")
-					return
-				}
-				copy(img.Pix[min:max], buf[i0:i1])
+			bitReader := newBitsReader(buf[off : off+rowSize])
+			bitReader.skipBits(uint((rect.Min.X - r.Min.X) * spp * bpp))
+			i := dst.PixOffset(rect.Min.X, y)
+			for n := rect.Dx() * spp; n > 0; n-- {
+				v, _ := bitReader.ReadBits(uint(bpp))
+				dst.XPix[i] = uint8(v)
+				i++
 			}
 		}
-	default:
-		err = fmt.Errorf("tiff: IFD.decodeBlock, unknown imageType: %v", p.ImageType())
 		return
 	}
 
+	pixelSize := spp * bpp / 8
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		off := (y-r.Min.Y)*rowSize + (rect.Min.X-r.Min.X)*pixelSize
+		if off+rect.Dx()*pixelSize > len(buf) {
+			return fmt.Errorf("tiff: IFD.decodeBlockRaw, not enough pixel data")
+		}
+		min := dst.PixOffset(rect.Min.X, y)
+		max := dst.PixOffset(rect.Max.X, y)
+		copy(dst.XPix[min:max], buf[off:])
+		if (p.Header.ByteOrder == binary.LittleEndian) != isLittleEndian {
+			dst.XPix[min:max].SwapEndian(dst.XDataType)
+		}
+	}
 	return
 }
 
@@ -8,7 +8,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
 )
 
 type IFDEntry struct {
@@ -17,7 +19,61 @@ type IFDEntry struct {
 	DataType DataType
 	Count    int
 	Offset   int64
-	Data     []byte
+
+	// Data holds the values of the entry. It is nil for the large entries
+	// of a Reader opened by OpenReaderAt until Load is called, the getters
+	// load them on demand and return no value when the read fails. Call
+	// Load to get its error.
+	Data []byte
+
+	lazy *lazyEntryData
+}
+
+// lazyEntryData reads the values of an entry when they are first used.
+type lazyEntryData struct {
+	once   sync.Once
+	r      io.ReaderAt
+	offset int64
+	size   int
+	data   []byte
+	err    error
+}
+
+func (p *lazyEntryData) load() ([]byte, error) {
+	p.once.Do(func() {
+		data := make([]byte, p.size)
+		n, err := p.r.ReadAt(data, p.offset)
+		if err == io.EOF && n == p.size {
+			err = nil
+		}
+		if err != nil {
+			p.err = fmt.Errorf("tiff: IFDEntry.Load, %v", err)
+			return
+		}
+		p.data = data
+	})
+	return p.data, p.err
+}
+
+// Load reads the values of an entry of a Reader opened by OpenReaderAt
+// into Data, if they are not read yet.
+func (p *IFDEntry) Load() (err error) {
+	if p.Data != nil || p.lazy == nil {
+		return
+	}
+	p.Data, err = p.lazy.load()
+	return
+}
+
+// data returns the values of the entry, reading them if needed. It does
+// not modify p, so that the entries can be read concurrently. A failed read
+// returns nil, Load returns its error.
+func (p *IFDEntry) data() []byte {
+	if p.Data == nil && p.lazy != nil {
+		data, _ := p.lazy.load()
+		return data
+	}
+	return p.Data
 }
 
 type byIFDEntry []*IFDEntry
@@ -43,7 +99,7 @@ func (p *IFDEntry) Valid() bool {
 	if !p.Header.Valid() || !p.Tag.Valid() || !p.DataType.Valid() {
 		return false
 	}
-	if p.Count <= 0 || len(p.Data) == 0 {
+	if p.Count <= 0 || len(p.data()) == 0 {
 		return false
 	}
 	return true
@@ -57,11 +113,11 @@ func (p *IFDEntry) Bytes() (entry, data []byte) {
 		binary.Write(&buf, p.Header.ByteOrder, uint32(p.Count))
 
 		offsetOrData := make([]byte, 4)
-		if len(p.Data) > 4 {
+		if len(p.data()) > 4 {
 			p.Header.ByteOrder.PutUint32(offsetOrData, uint32(p.Offset))
-			data = p.Data
+			data = p.data()
 		} else {
-			copy(offsetOrData[:], p.Data)
+			copy(offsetOrData[:], p.data())
 		}
 		buf.Write(offsetOrData)
 		entry = buf.Bytes()
@@ -73,11 +129,11 @@ func (p *IFDEntry) Bytes() (entry, data []byte) {
 		binary.Write(&buf, p.Header.ByteOrder, uint64(p.Count))
 
 		offsetOrData := make([]byte, 8)
-		if len(p.Data) > 8 {
+		if len(p.data()) > 8 {
 			p.Header.ByteOrder.PutUint64(offsetOrData, uint64(p.Offset))
-			data = p.Data
+			data = p.data()
 		} else {
-			copy(offsetOrData[:], p.Data)
+			copy(offsetOrData[:], p.data())
 		}
 		buf.Write(offsetOrData)
 		entry = buf.Bytes()
@@ -88,19 +144,27 @@ func (p *IFDEntry) Bytes() (entry, data []byte) {
 func (p *IFDEntry) GetInts() []int64 {
 	switch p.DataType {
 	case DataType_Byte:
+		data := p.data()
+		if len(data) < p.Count {
+			return nil
+		}
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
-			dst[i] = int64(int8(p.Data[i]))
+			dst[i] = int64(uint8(data[i]))
 		}
 		return dst
 	case DataType_SByte:
+		data := p.data()
+		if len(data) < p.Count {
+			return nil
+		}
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
-			dst[i] = int64(uint8(p.Data[i]))
+			dst[i] = int64(int8(data[i]))
 		}
 		return dst
 	case DataType_Short:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v uint16
@@ -111,7 +175,7 @@ func (p *IFDEntry) GetInts() []int64 {
 		}
 		return dst
 	case DataType_SShort:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v int16
@@ -122,7 +186,7 @@ func (p *IFDEntry) GetInts() []int64 {
 		}
 		return dst
 	case DataType_Long, DataType_IFD:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v uint32
@@ -133,7 +197,7 @@ func (p *IFDEntry) GetInts() []int64 {
 		}
 		return dst
 	case DataType_SLong:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v int32
@@ -144,7 +208,7 @@ func (p *IFDEntry) GetInts() []int64 {
 		}
 		return dst
 	case DataType_Long8, DataType_IFD8:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v uint64
@@ -155,7 +219,7 @@ func (p *IFDEntry) GetInts() []int64 {
 		}
 		return dst
 	case DataType_SLong8:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v int64
@@ -172,7 +236,7 @@ func (p *IFDEntry) GetInts() []int64 {
 func (p *IFDEntry) GetFloats() []float64 {
 	switch p.DataType {
 	case DataType_Float:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]float64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v float32
@@ -183,7 +247,7 @@ func (p *IFDEntry) GetFloats() []float64 {
 		}
 		return dst
 	case DataType_Double:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([]float64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v float64
@@ -213,7 +277,7 @@ func (p *IFDEntry) GetFloats() []float64 {
 func (p *IFDEntry) GetRationals() [][2]int64 {
 	switch p.DataType {
 	case DataType_Rational:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([][2]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v [2]uint32
@@ -225,7 +289,7 @@ func (p *IFDEntry) GetRationals() [][2]int64 {
 		}
 		return dst
 	case DataType_SRational:
-		r := bytes.NewReader(p.Data)
+		r := bytes.NewReader(p.data())
 		dst := make([][2]int64, p.Count)
 		for i := 0; i < p.Count; i++ {
 			var v [2]int32
@@ -243,10 +307,11 @@ func (p *IFDEntry) GetRationals() [][2]int64 {
 func (p *IFDEntry) GetString() string {
 	switch p.DataType {
 	case DataType_ASCII:
-		if idx := bytes.Index(p.Data, []byte("\000")); idx >= 0 {
-			return string(p.Data[:idx])
+		data := p.data()
+		if idx := bytes.Index(data, []byte("\000")); idx >= 0 {
+			return string(data[:idx])
 		}
-		return string(p.Data)
+		return string(data)
 	}
 	return ""
 }
@@ -259,7 +324,7 @@ func (p *IFDEntry) GetUndefined(value interface{}) interface{} {
 	if p.DataType != DataType_Undefined {
 		return nil
 	}
-	if err := binary.Read(bytes.NewReader(p.Data), p.Header.ByteOrder, value); err != nil {
+	if err := binary.Read(bytes.NewReader(p.data()), p.Header.ByteOrder, value); err != nil {
 		return nil
 	}
 	return value
@@ -441,7 +506,7 @@ func (p *IFDEntry) SetString(value string) (err error) {
 		p.Data = make([]byte, len(value)+1)
 		copy(p.Data, []byte(value))
 		p.Data[len(value)] = 0 // +NULL
-		p.Count = len(p.Data) + 1
+		p.Count = len(p.Data)
 	}
 	return
 }
@@ -497,6 +562,14 @@ func (p *IFDEntry) String() string {
 		if v := p.GetInts(); len(v) == 1 {
 			return fmt.Sprintf("%v(%v): %v", p.Tag, p.DataType, TagValue_PhotometricType(v[0]))
 		}
+	case TagType_FillOrder:
+		if v := p.GetInts(); len(v) == 1 {
+			return fmt.Sprintf("%v(%v): %v", p.Tag, p.DataType, TagValue_FillOrderType(v[0]))
+		}
+	case TagType_Orientation:
+		if v := p.GetInts(); len(v) == 1 {
+			return fmt.Sprintf("%v(%v): %v", p.Tag, p.DataType, TagValue_OrientationType(v[0]))
+		}
 	case TagType_RowsPerStrip:
 		if v := p.GetInts(); len(v) == 1 {
 			return fmt.Sprintf("%v(%v): %v", p.Tag, p.DataType, int(v[0]))
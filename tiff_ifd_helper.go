@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"reflect"
 	"sort"
 )
 
@@ -30,7 +31,11 @@ func (p *IFD) TagGetter() TagGetter {
 }
 
 func (p *IFD) TagSetter() TagSetter {
+	if p.EntryMap == nil {
+		p.EntryMap = make(map[TagType]*IFDEntry)
+	}
 	return &tifTagSetter{
+		Header:   p.Header,
 		EntryMap: p.EntryMap,
 	}
 }
@@ -67,6 +72,38 @@ func (p *IFD) Channels() int {
 	return 0
 }
 
+// Kind returns the type of one sample, as given by SampleFormat and
+// BitsPerSample. Samples smaller than one byte are reported as reflect.Uint8.
+func (p *IFD) Kind() reflect.Kind {
+	var format = TagValue_SampleFormatType_Uint
+	if v, ok := p.TagGetter().GetSampleFormat(); ok && len(v) > 0 {
+		format = TagValue_SampleFormatType(v[0])
+	}
+	switch depth := p.Depth(); {
+	case format == TagValue_SampleFormatType_Float && depth == 32:
+		return reflect.Float32
+	case format == TagValue_SampleFormatType_Float && depth == 64:
+		return reflect.Float64
+	case format == TagValue_SampleFormatType_TwoInt && depth == 8:
+		return reflect.Int8
+	case format == TagValue_SampleFormatType_TwoInt && depth == 16:
+		return reflect.Int16
+	case format == TagValue_SampleFormatType_TwoInt && depth == 32:
+		return reflect.Int32
+	case format == TagValue_SampleFormatType_TwoInt && depth == 64:
+		return reflect.Int64
+	case depth > 0 && depth <= 8:
+		return reflect.Uint8
+	case depth == 16:
+		return reflect.Uint16
+	case depth == 32:
+		return reflect.Uint32
+	case depth == 64:
+		return reflect.Uint64
+	}
+	return reflect.Invalid
+}
+
 func (p *IFD) ImageType() ImageType {
 	var requiredTags = []TagType{
 		TagType_ImageWidth,
@@ -122,7 +159,7 @@ func (p *IFD) ImageType() ImageType {
 	var (
 		photometric, _                = p.TagGetter().GetPhotometricInterpretation()
 		bitsPerSample, _              = p.TagGetter().GetBitsPerSample()
-		extraSamples, hasExtraSamples = p.TagGetter().GetExtraSamples()
+		extraSamples, hasExtraSamples = p.extraSample()
 	)
 
 	switch photometric {
@@ -166,13 +203,23 @@ func (p *IFD) ImageType() ImageType {
 	return ImageType_Nil
 }
 
+// extraSample returns the ExtraSamples tag of an image with a single extra
+// sample, such as the alpha channel of an RGBA image.
+func (p *IFD) extraSample() (value int64, ok bool) {
+	v, ok := p.TagGetter().GetExtraSamples()
+	if !ok || len(v) != 1 {
+		return 0, false
+	}
+	return v[0], true
+}
+
 func (p *IFD) ImageConfig() (config image.Config, err error) {
 	var (
 		imageWidth, _    = p.TagGetter().GetImageWidth()
 		imageHeight, _   = p.TagGetter().GetImageLength()
 		photometric, _   = p.TagGetter().GetPhotometricInterpretation()
 		bitsPerSample, _ = p.TagGetter().GetBitsPerSample()
-		extraSamples, _  = p.TagGetter().GetExtraSamples()
+		extraSamples, _  = p.extraSample()
 	)
 	if len(bitsPerSample) == 0 {
 		err = fmt.Errorf("tiff: IFD.ColorModel, bad bitsPerSample length")
@@ -262,6 +309,72 @@ func (p *IFD) Compression() TagValue_CompressionType {
 	return TagValue_CompressionType_Nil
 }
 
+// Orientation returns the Orientation tag of the image, or TopLeft when the
+// tag is missing or holds an invalid value.
+func (p *IFD) Orientation() TagValue_OrientationType {
+	if v, ok := p.TagGetter().GetOrientation(); ok {
+		if o := TagValue_OrientationType(v); o.Valid() {
+			return o
+		}
+	}
+	return TagValue_OrientationType_TopLeft
+}
+
+// Resolution returns the number of pixels per unit in the horizontal and
+// vertical directions. The unit is PerInch, the TIFF default, when the
+// ResolutionUnit tag is missing. ok is false when XResolution or
+// YResolution is missing or invalid.
+func (p *IFD) Resolution() (x, y float64, unit TagValue_ResolutionUnitType, ok bool) {
+	rx, ok1 := p.TagGetter().GetXResolution()
+	ry, ok2 := p.TagGetter().GetYResolution()
+	if !ok1 || !ok2 || rx[1] == 0 || ry[1] == 0 {
+		return
+	}
+	if unit, ok = p.TagGetter().GetResolutionUnit(); !ok {
+		return
+	}
+	x, y = float64(rx[0])/float64(rx[1]), float64(ry[0])/float64(ry[1])
+	return
+}
+
+// DPI returns the resolution in pixels per inch. ok is false when the
+// resolution is missing or has no absolute unit.
+func (p *IFD) DPI() (x, y float64, ok bool) {
+	x, y, unit, ok := p.Resolution()
+	switch {
+	case !ok:
+	case unit == TagValue_ResolutionUnitType_PerInch:
+	case unit == TagValue_ResolutionUnitType_PerCM:
+		x, y = x*2.54, y*2.54
+	default:
+		x, y, ok = 0, 0, false
+	}
+	return
+}
+
+// DPCM returns the resolution in pixels per centimeter. ok is false when
+// the resolution is missing or has no absolute unit.
+func (p *IFD) DPCM() (x, y float64, ok bool) {
+	if x, y, ok = p.DPI(); ok {
+		x, y = x/2.54, y/2.54
+	}
+	return
+}
+
+// IsReduced reports whether the image is a reduced resolution version of
+// another image (bit 0 of NewSubfileType).
+func (p *IFD) IsReduced() bool {
+	v, _ := p.TagGetter().GetNewSubfileType()
+	return v&int64(TagValue_NewSubfileType_Reduced) != 0
+}
+
+// IsMask reports whether the image is a transparency mask for another
+// image (bit 2 of NewSubfileType).
+func (p *IFD) IsMask() bool {
+	v, _ := p.TagGetter().GetNewSubfileType()
+	return v&int64(TagValue_NewSubfileType_Mask) != 0
+}
+
 func (p *IFD) ColorMap() (palette color.Palette) {
 	v, ok := p.TagGetter().GetColorMap()
 	if !ok {
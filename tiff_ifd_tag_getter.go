@@ -286,8 +286,8 @@ func (p *tifTagGetter) GetXResolution() (value [2]int64, ok bool) {
 	if entry, ok = p.EntryMap[TagType_XResolution]; !ok {
 		return
 	}
-	if v := entry.GetInts(); len(v) == 2 {
-		value = [2]int64{v[0], v[1]}
+	if v := entry.GetRationals(); len(v) == 1 {
+		value = v[0]
 	} else {
 		ok = false
 	}
@@ -299,8 +299,8 @@ func (p *tifTagGetter) GetYResolution() (value [2]int64, ok bool) {
 	if entry, ok = p.EntryMap[TagType_YResolution]; !ok {
 		return
 	}
-	if v := entry.GetInts(); len(v) == 2 {
-		value = [2]int64{v[0], v[1]}
+	if v := entry.GetRationals(); len(v) == 1 {
+		value = v[0]
 	} else {
 		ok = false
 	}
@@ -336,8 +336,8 @@ func (p *tifTagGetter) GetXPosition() (value [2]int64, ok bool) {
 	if entry, ok = p.EntryMap[TagType_XPosition]; !ok {
 		return
 	}
-	if v := entry.GetInts(); len(v) == 2 {
-		value = [2]int64{v[0], v[1]}
+	if v := entry.GetRationals(); len(v) == 1 {
+		value = v[0]
 	} else {
 		ok = false
 	}
@@ -349,8 +349,8 @@ func (p *tifTagGetter) GetYPosition() (value [2]int64, ok bool) {
 	if entry, ok = p.EntryMap[TagType_YPosition]; !ok {
 		return
 	}
-	if v := entry.GetInts(); len(v) == 2 {
-		value = [2]int64{v[0], v[1]}
+	if v := entry.GetRationals(); len(v) == 1 {
+		value = v[0]
 	} else {
 		ok = false
 	}
@@ -482,7 +482,7 @@ func (p *tifTagGetter) GetDateTime() (value time.Time, ok bool) {
 		ok = false
 		return
 	}
-	value = time.Date(year, time.Month(month), day, hour, min, sec, 0, nil)
+	value = time.Date(year, time.Month(month), day, hour, min, sec, 0, time.UTC)
 	return
 }
 
@@ -676,16 +676,12 @@ func (p *tifTagGetter) GetTargetPrinter() (value string, ok bool) {
 	return
 }
 
-func (p *tifTagGetter) GetExtraSamples() (value int64, ok bool) {
+func (p *tifTagGetter) GetExtraSamples() (value []int64, ok bool) {
 	var entry *IFDEntry
 	if entry, ok = p.EntryMap[TagType_ExtraSamples]; !ok {
 		return
 	}
-	if v := entry.GetInts(); len(v) == 1 {
-		value = v[0]
-	} else {
-		ok = false
-	}
+	value = entry.GetInts()
 	return
 }
 
@@ -803,6 +799,7 @@ func (p *tifTagGetter) GetJPEGTables() (value []byte, ok bool) {
 		return
 	}
 	_, value = entry.Bytes()
+	value = append([]byte(nil), value...)
 	return
 }
 
@@ -877,6 +874,16 @@ func (p *tifTagGetter) GetReferenceBlackWhite() (value []int64, ok bool) {
 	return
 }
 
+func (p *tifTagGetter) GetXMP() (value []byte, ok bool) {
+	var entry *IFDEntry
+	if entry, ok = p.EntryMap[TagType_XMP]; !ok {
+		return
+	}
+	_, value = entry.Bytes()
+	value = append([]byte(nil), value...)
+	return
+}
+
 func (p *tifTagGetter) GetCopyright() (value string, ok bool) {
 	var entry *IFDEntry
 	if entry, ok = p.EntryMap[TagType_Copyright]; !ok {
@@ -981,7 +988,7 @@ func (p *tifTagGetter) GetUnknown(tag TagType) (value []byte, ok bool) {
 	if entry, ok = p.EntryMap[tag]; !ok {
 		return
 	}
-	value = entry.Data
+	value = entry.data()
 	return
 }
 
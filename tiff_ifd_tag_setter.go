@@ -4,9 +4,577 @@
 
 package tiff
 
+import (
+	"time"
+)
+
 var _ TagSetter = (*tifTagSetter)(nil)
 
+// tifTagSetter creates the entries of EntryMap. The data type of an entry
+// is the first type of _TagType_TypesTable which holds the values, and the
+// number of values must match _TagType_NumsTable. A setter returns false,
+// leaving EntryMap as is, when the value does not fit the tag.
 type tifTagSetter struct {
+	Header   *Header
 	EntryMap map[TagType]*IFDEntry
-	TagSetter
+}
+
+func (p *tifTagSetter) SetNewSubfileType(value int64) (ok bool) {
+	return p.setInts(TagType_NewSubfileType, value)
+}
+
+func (p *tifTagSetter) SetSubfileType(value int64) (ok bool) {
+	return p.setInts(TagType_SubfileType, value)
+}
+
+func (p *tifTagSetter) SetImageWidth(value int64) (ok bool) {
+	return p.setInts(TagType_ImageWidth, value)
+}
+
+func (p *tifTagSetter) SetImageLength(value int64) (ok bool) {
+	return p.setInts(TagType_ImageLength, value)
+}
+
+func (p *tifTagSetter) SetBitsPerSample(value []int64) (ok bool) {
+	return p.setInts(TagType_BitsPerSample, value...)
+}
+
+func (p *tifTagSetter) SetCompression(value TagValue_CompressionType) (ok bool) {
+	return p.setInts(TagType_Compression, int64(value))
+}
+
+func (p *tifTagSetter) SetPhotometricInterpretation(value TagValue_PhotometricType) (ok bool) {
+	return p.setInts(TagType_PhotometricInterpretation, int64(value))
+}
+
+func (p *tifTagSetter) SetThreshholding(value int64) (ok bool) {
+	return p.setInts(TagType_Threshholding, value)
+}
+
+func (p *tifTagSetter) SetCellWidth(value int64) (ok bool) {
+	return p.setInts(TagType_CellWidth, value)
+}
+
+func (p *tifTagSetter) SetCellLenght(value int64) (ok bool) {
+	return p.setInts(TagType_CellLenght, value)
+}
+
+func (p *tifTagSetter) SetFillOrder(value int64) (ok bool) {
+	return p.setInts(TagType_FillOrder, value)
+}
+
+func (p *tifTagSetter) SetDocumentName(value string) (ok bool) {
+	return p.setString(TagType_DocumentName, value)
+}
+
+func (p *tifTagSetter) SetImageDescription(value string) (ok bool) {
+	return p.setString(TagType_ImageDescription, value)
+}
+
+func (p *tifTagSetter) SetMake(value string) (ok bool) {
+	return p.setString(TagType_Make, value)
+}
+
+func (p *tifTagSetter) SetModel(value string) (ok bool) {
+	return p.setString(TagType_Model, value)
+}
+
+func (p *tifTagSetter) SetStripOffsets(value []int64) (ok bool) {
+	return p.setInts(TagType_StripOffsets, value...)
+}
+
+func (p *tifTagSetter) SetOrientation(value int64) (ok bool) {
+	return p.setInts(TagType_Orientation, value)
+}
+
+func (p *tifTagSetter) SetSamplesPerPixel(value int64) (ok bool) {
+	return p.setInts(TagType_SamplesPerPixel, value)
+}
+
+func (p *tifTagSetter) SetRowsPerStrip(value int64) (ok bool) {
+	return p.setInts(TagType_RowsPerStrip, value)
+}
+
+func (p *tifTagSetter) SetStripByteCounts(value []int64) (ok bool) {
+	return p.setInts(TagType_StripByteCounts, value...)
+}
+
+func (p *tifTagSetter) SetMinSampleValue(value []int64) (ok bool) {
+	return p.setInts(TagType_MinSampleValue, value...)
+}
+
+func (p *tifTagSetter) SetMaxSampleValue(value []int64) (ok bool) {
+	return p.setInts(TagType_MaxSampleValue, value...)
+}
+
+func (p *tifTagSetter) SetXResolution(value [2]int64) (ok bool) {
+	return p.setRationals(TagType_XResolution, value)
+}
+
+func (p *tifTagSetter) SetYResolution(value [2]int64) (ok bool) {
+	return p.setRationals(TagType_YResolution, value)
+}
+
+func (p *tifTagSetter) SetPlanarConfiguration(value int64) (ok bool) {
+	return p.setInts(TagType_PlanarConfiguration, value)
+}
+
+func (p *tifTagSetter) SetPageName(value string) (ok bool) {
+	return p.setString(TagType_PageName, value)
+}
+
+func (p *tifTagSetter) SetXPosition(value [2]int64) (ok bool) {
+	return p.setRationals(TagType_XPosition, value)
+}
+
+func (p *tifTagSetter) SetYPosition(value [2]int64) (ok bool) {
+	return p.setRationals(TagType_YPosition, value)
+}
+
+func (p *tifTagSetter) SetFreeOffsets(value []int64) (ok bool) {
+	return p.setInts(TagType_FreeOffsets, value...)
+}
+
+func (p *tifTagSetter) SetFreeByteCounts(value []int64) (ok bool) {
+	return p.setInts(TagType_FreeByteCounts, value...)
+}
+
+func (p *tifTagSetter) SetGrayResponseUnit(value int64) (ok bool) {
+	return p.setInts(TagType_GrayResponseUnit, value)
+}
+
+func (p *tifTagSetter) SetGrayResponseCurve(value []int64) (ok bool) {
+	return p.setInts(TagType_GrayResponseCurve, value...)
+}
+
+func (p *tifTagSetter) SetT4Options(value int64) (ok bool) {
+	return p.setInts(TagType_T4Options, value)
+}
+
+func (p *tifTagSetter) SetT6Options(value int64) (ok bool) {
+	return p.setInts(TagType_T6Options, value)
+}
+
+func (p *tifTagSetter) SetResolutionUnit(value TagValue_ResolutionUnitType) (ok bool) {
+	return p.setInts(TagType_ResolutionUnit, int64(value))
+}
+
+func (p *tifTagSetter) SetPageNumber(value []int64) (ok bool) {
+	return p.setInts(TagType_PageNumber, value...)
+}
+
+func (p *tifTagSetter) SetTransferFunction(value []int64) (ok bool) {
+	return p.setInts(TagType_TransferFunction, value...)
+}
+
+func (p *tifTagSetter) SetSoftware(value string) (ok bool) {
+	return p.setString(TagType_Software, value)
+}
+
+func (p *tifTagSetter) SetDateTime(value time.Time) (ok bool) {
+	return p.setString(TagType_DateTime, value.Format("2006:01:02 15:04:05"))
+}
+
+func (p *tifTagSetter) SetArtist(value string) (ok bool) {
+	return p.setString(TagType_Artist, value)
+}
+
+func (p *tifTagSetter) SetHostComputer(value string) (ok bool) {
+	return p.setString(TagType_HostComputer, value)
+}
+
+func (p *tifTagSetter) SetPredictor(value TagValue_PredictorType) (ok bool) {
+	return p.setInts(TagType_Predictor, int64(value))
+}
+
+func (p *tifTagSetter) SetWhitePoint(value [][2]int64) (ok bool) {
+	return p.setRationals(TagType_WhitePoint, value...)
+}
+
+func (p *tifTagSetter) SetPrimaryChromaticities(value [][2]int64) (ok bool) {
+	return p.setRationals(TagType_PrimaryChromaticities, value...)
+}
+
+// SetColorMap stores the palette as three planes of red, green and blue
+// values. It must have 2**BitsPerSample colors, when BitsPerSample is set.
+func (p *tifTagSetter) SetColorMap(value [][3]uint16) (ok bool) {
+	if len(value) == 0 || len(value)&(len(value)-1) != 0 {
+		return false
+	}
+	if v, ok := p.EntryMap[TagType_BitsPerSample]; ok {
+		if bits := v.GetInts(); len(bits) != 1 || bits[0] > 16 || len(value) != 1<<uint(bits[0]) {
+			return false
+		}
+	}
+	ints := make([]int64, 3*len(value))
+	for i, c := range value {
+		ints[i+0*len(value)] = int64(c[0])
+		ints[i+1*len(value)] = int64(c[1])
+		ints[i+2*len(value)] = int64(c[2])
+	}
+	return p.setInts(TagType_ColorMap, ints...)
+}
+
+func (p *tifTagSetter) SetHalftoneHints(value []int64) (ok bool) {
+	return p.setInts(TagType_HalftoneHints, value...)
+}
+
+func (p *tifTagSetter) SetTileWidth(value int64) (ok bool) {
+	return p.setInts(TagType_TileWidth, value)
+}
+
+func (p *tifTagSetter) SetTileLength(value int64) (ok bool) {
+	return p.setInts(TagType_TileLength, value)
+}
+
+func (p *tifTagSetter) SetTileOffsets(value []int64) (ok bool) {
+	return p.setInts(TagType_TileOffsets, value...)
+}
+
+func (p *tifTagSetter) SetTileByteCounts(value []int64) (ok bool) {
+	return p.setInts(TagType_TileByteCounts, value...)
+}
+
+func (p *tifTagSetter) SetSubIFD(value []int64) (ok bool) {
+	return p.setInts(TagType_SubIFD, value...)
+}
+
+func (p *tifTagSetter) SetInkSet(value int64) (ok bool) {
+	return p.setInts(TagType_InkSet, value)
+}
+
+func (p *tifTagSetter) SetInkNames(value string) (ok bool) {
+	return p.setString(TagType_InkNames, value)
+}
+
+func (p *tifTagSetter) SetNumberOfInks(value int64) (ok bool) {
+	return p.setInts(TagType_NumberOfInks, value)
+}
+
+func (p *tifTagSetter) SetDotRange(value []int64) (ok bool) {
+	return p.setInts(TagType_DotRange, value...)
+}
+
+func (p *tifTagSetter) SetTargetPrinter(value string) (ok bool) {
+	return p.setString(TagType_TargetPrinter, value)
+}
+
+func (p *tifTagSetter) SetExtraSamples(value []int64) (ok bool) {
+	return p.setInts(TagType_ExtraSamples, value...)
+}
+
+func (p *tifTagSetter) SetSampleFormat(value []int64) (ok bool) {
+	return p.setInts(TagType_SampleFormat, value...)
+}
+
+func (p *tifTagSetter) SetSMinSampleValue(value []float64) (ok bool) {
+	return p.setFloats(TagType_SMinSampleValue, value...)
+}
+
+func (p *tifTagSetter) SetSMaxSampleValue(value []float64) (ok bool) {
+	return p.setFloats(TagType_SMaxSampleValue, value...)
+}
+
+func (p *tifTagSetter) SetTransferRange(value []int64) (ok bool) {
+	return p.setInts(TagType_TransferRange, value...)
+}
+
+func (p *tifTagSetter) SetJPEGProc(value int64) (ok bool) {
+	return p.setInts(TagType_JPEGProc, value)
+}
+
+func (p *tifTagSetter) SetJPEGInterchangeFormat(value int64) (ok bool) {
+	return p.setInts(TagType_JPEGInterchangeFormat, value)
+}
+
+func (p *tifTagSetter) SetJPEGInterchangeFormatLength(value int64) (ok bool) {
+	return p.setInts(TagType_JPEGInterchangeFormatLength, value)
+}
+
+func (p *tifTagSetter) SetJPEGRestartInterval(value int64) (ok bool) {
+	return p.setInts(TagType_JPEGRestartInterval, value)
+}
+
+func (p *tifTagSetter) SetJPEGLosslessPredictors(value []int64) (ok bool) {
+	return p.setInts(TagType_JPEGLosslessPredictors, value...)
+}
+
+func (p *tifTagSetter) SetJPEGPointTransforms(value []int64) (ok bool) {
+	return p.setInts(TagType_JPEGPointTransforms, value...)
+}
+
+func (p *tifTagSetter) SetJPEGTables(value []byte) (ok bool) {
+	return p.setBytes(TagType_JPEGTables, value)
+}
+
+func (p *tifTagSetter) SetJPEGQTables(value []int64) (ok bool) {
+	return p.setInts(TagType_JPEGQTables, value...)
+}
+
+func (p *tifTagSetter) SetJPEGDCTables(value []int64) (ok bool) {
+	return p.setInts(TagType_JPEGDCTables, value...)
+}
+
+func (p *tifTagSetter) SetJPEGACTables(value []int64) (ok bool) {
+	return p.setInts(TagType_JPEGACTables, value...)
+}
+
+func (p *tifTagSetter) SetYCbCrCoefficients(value [][2]int64) (ok bool) {
+	return p.setRationals(TagType_YCbCrCoefficients, value...)
+}
+
+func (p *tifTagSetter) SetYCbCrSubSampling(value []int64) (ok bool) {
+	return p.setInts(TagType_YCbCrSubSampling, value...)
+}
+
+func (p *tifTagSetter) SetYCbCrPositioning(value int64) (ok bool) {
+	return p.setInts(TagType_YCbCrPositioning, value)
+}
+
+func (p *tifTagSetter) SetReferenceBlackWhite(value []int64) (ok bool) {
+	return p.setInts(TagType_ReferenceBlackWhite, value...)
+}
+
+func (p *tifTagSetter) SetXMP(value []byte) (ok bool) {
+	return p.setBytes(TagType_XMP, value)
+}
+
+func (p *tifTagSetter) SetCopyright(value string) (ok bool) {
+	return p.setString(TagType_Copyright, value)
+}
+
+func (p *tifTagSetter) SetModelPixelScaleTag(value []float64) (ok bool) {
+	return p.setFloats(TagType_ModelPixelScaleTag, value...)
+}
+
+func (p *tifTagSetter) SetIrasBTransformationMatrix(value []float64) (ok bool) {
+	return p.setFloats(TagType_IrasBTransformationMatrix, value...)
+}
+
+func (p *tifTagSetter) SetModelTiepointTag(value []float64) (ok bool) {
+	return p.setFloats(TagType_ModelTiepointTag, value...)
+}
+
+func (p *tifTagSetter) SetModelTransformationTag(value []float64) (ok bool) {
+	return p.setFloats(TagType_ModelTransformationTag, value...)
+}
+
+func (p *tifTagSetter) SetExifIFD(value []int64) (ok bool) {
+	return p.setInts(TagType_ExifIFD, value...)
+}
+
+func (p *tifTagSetter) SetGeoKeyDirectoryTag(value []int64) (ok bool) {
+	return p.setInts(TagType_GeoKeyDirectoryTag, value...)
+}
+
+func (p *tifTagSetter) SetGeoDoubleParamsTag(value []float64) (ok bool) {
+	return p.setFloats(TagType_GeoDoubleParamsTag, value...)
+}
+
+func (p *tifTagSetter) SetGeoAsciiParamsTag(value string) (ok bool) {
+	return p.setString(TagType_GeoAsciiParamsTag, value)
+}
+
+func (p *tifTagSetter) SetGPSIFD(value []int64) (ok bool) {
+	return p.setInts(TagType_GPSIFD, value...)
+}
+
+func (p *tifTagSetter) SetInteroperabilityIFD(value []int64) (ok bool) {
+	return p.setInts(TagType_InteroperabilityIFD, value...)
+}
+
+// SetUnknown sets tag to value, which is a string, a []byte, an int64, a
+// float64, a [2]int64 rational, or a slice of them. The data type comes
+// from _TagType_TypesTable for the known tags. Otherwise it is ASCII,
+// Undefined, Long (Long8 for BigTIFF), Double or Rational.
+func (p *tifTagSetter) SetUnknown(tag TagType, value interface{}) (ok bool) {
+	switch value := value.(type) {
+	case string:
+		return p.setString(tag, value)
+	case []byte:
+		return p.setBytes(tag, value)
+	case int64:
+		return p.setInts(tag, value)
+	case []int64:
+		return p.setInts(tag, value...)
+	case float64:
+		return p.setFloats(tag, value)
+	case []float64:
+		return p.setFloats(tag, value...)
+	case [2]int64:
+		return p.setRationals(tag, value)
+	case [][2]int64:
+		return p.setRationals(tag, value...)
+	}
+	return false
+}
+
+func (p *tifTagSetter) private() {
+	return
+}
+
+func (p *tifTagSetter) header() *Header {
+	if p.Header != nil {
+		return p.Header
+	}
+	return NewHeader(false, 8)
+}
+
+// dataType returns the first data type accepted by tag for which fit
+// returns true. Tags missing from _TagType_TypesTable use defaultType.
+func (p *tifTagSetter) dataType(tag TagType, defaultType DataType, fit func(DataType) bool) (DataType, bool) {
+	types, ok := _TagType_TypesTable[tag]
+	if !ok {
+		types = []DataType{defaultType}
+	}
+	for _, t := range types {
+		if fit(t) {
+			return t, true
+		}
+	}
+	return DataType_Nil, false
+}
+
+// validCount reports whether n values fit the count of tag.
+func (p *tifTagSetter) validCount(tag TagType, n int) bool {
+	if n == 0 {
+		return false
+	}
+	nums, ok := _TagType_NumsTable[tag]
+	if !ok {
+		return true
+	}
+	for _, v := range nums {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *tifTagSetter) setEntry(entry *IFDEntry) bool {
+	if !p.validCount(entry.Tag, entry.Count) {
+		return false
+	}
+	p.EntryMap[entry.Tag] = entry
+	return true
+}
+
+func (p *tifTagSetter) setInts(tag TagType, value ...int64) (ok bool) {
+	h := p.header()
+	defaultType := DataType_Long
+	if h.IsBigTiff() {
+		defaultType = DataType_Long8
+	}
+	dataType, ok := p.dataType(tag, defaultType, func(t DataType) bool {
+		var min, max int64
+		switch t {
+		case DataType_Byte:
+			min, max = 0, 0xff
+		case DataType_SByte:
+			min, max = -0x80, 0x7f
+		case DataType_Short:
+			min, max = 0, 0xffff
+		case DataType_SShort:
+			min, max = -0x8000, 0x7fff
+		case DataType_Long, DataType_IFD:
+			min, max = 0, 0xffffffff
+		case DataType_SLong:
+			min, max = -0x80000000, 0x7fffffff
+		case DataType_Long8, DataType_IFD8:
+			if !h.IsBigTiff() {
+				return false
+			}
+			min, max = 0, 1<<63-1
+		case DataType_SLong8:
+			if !h.IsBigTiff() {
+				return false
+			}
+			min, max = -1<<63, 1<<63-1
+		default:
+			return false
+		}
+		for _, v := range value {
+			if v < min || v > max {
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		return false
+	}
+	entry := &IFDEntry{Header: h, Tag: tag, DataType: dataType}
+	if err := entry.SetInts(value...); err != nil {
+		return false
+	}
+	return p.setEntry(entry)
+}
+
+func (p *tifTagSetter) setFloats(tag TagType, value ...float64) (ok bool) {
+	dataType, ok := p.dataType(tag, DataType_Double, func(t DataType) bool {
+		return t == DataType_Float || t == DataType_Double
+	})
+	if !ok {
+		return false
+	}
+	entry := &IFDEntry{Header: p.header(), Tag: tag, DataType: dataType}
+	if err := entry.SetFloats(value...); err != nil {
+		return false
+	}
+	return p.setEntry(entry)
+}
+
+func (p *tifTagSetter) setRationals(tag TagType, value ...[2]int64) (ok bool) {
+	dataType, ok := p.dataType(tag, DataType_Rational, func(t DataType) bool {
+		for _, v := range value {
+			switch {
+			case v[1] == 0:
+				return false
+			case t == DataType_Rational && (v[0] < 0 || v[0] > 0xffffffff || v[1] < 0 || v[1] > 0xffffffff):
+				return false
+			case t == DataType_SRational && (v[0] != int64(int32(v[0])) || v[1] != int64(int32(v[1]))):
+				return false
+			case t != DataType_Rational && t != DataType_SRational:
+				return false
+			}
+		}
+		return true
+	})
+	if !ok {
+		return false
+	}
+	entry := &IFDEntry{Header: p.header(), Tag: tag, DataType: dataType}
+	if err := entry.SetRationals(value...); err != nil {
+		return false
+	}
+	return p.setEntry(entry)
+}
+
+func (p *tifTagSetter) setString(tag TagType, value string) (ok bool) {
+	if _, ok = p.dataType(tag, DataType_ASCII, func(t DataType) bool { return t == DataType_ASCII }); !ok {
+		return false
+	}
+	entry := &IFDEntry{Header: p.header(), Tag: tag, DataType: DataType_ASCII}
+	if err := entry.SetString(value); err != nil {
+		return false
+	}
+	return p.setEntry(entry)
+}
+
+func (p *tifTagSetter) setBytes(tag TagType, value []byte) (ok bool) {
+	dataType, ok := p.dataType(tag, DataType_Undefined, func(t DataType) bool {
+		return t == DataType_Undefined || t == DataType_Byte
+	})
+	if !ok {
+		return false
+	}
+	entry := &IFDEntry{
+		Header:   p.header(),
+		Tag:      tag,
+		DataType: dataType,
+		Count:    len(value),
+		Data:     append([]byte(nil), value...),
+	}
+	return p.setEntry(entry)
 }
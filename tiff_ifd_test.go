@@ -3,3 +3,589 @@
 // license that can be found in the LICENSE file.
 
 package tiff
+
+import (
+	"bytes"
+	"image"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTagSetter(t *testing.T) {
+	var opt Options
+	setter, getter := opt.TagSetter(), opt.TagGetter()
+
+	if !setter.SetImageWidth(100) || !setter.SetImageLength(70000) {
+		t.Fatal("SetImageWidth/SetImageLength failed")
+	}
+	if dt := opt.EntryMap[TagType_ImageWidth].DataType; dt != DataType_Short {
+		t.Fatalf("ImageWidth: data type %v, want %v", dt, DataType_Short)
+	}
+	if dt := opt.EntryMap[TagType_ImageLength].DataType; dt != DataType_Long {
+		t.Fatalf("ImageLength: data type %v, want %v", dt, DataType_Long)
+	}
+	if v, ok := getter.GetImageLength(); !ok || v != 70000 {
+		t.Fatalf("GetImageLength = %v, %v", v, ok)
+	}
+
+	if !setter.SetCompression(TagValue_CompressionType_Deflate) {
+		t.Fatal("SetCompression failed")
+	}
+	if v, ok := getter.GetCompression(); !ok || v != TagValue_CompressionType_Deflate {
+		t.Fatalf("GetCompression = %v, %v", v, ok)
+	}
+	if !setter.SetXResolution([2]int64{300, 1}) {
+		t.Fatal("SetXResolution failed")
+	}
+	if v, ok := getter.GetXResolution(); !ok || v != [2]int64{300, 1} {
+		t.Fatalf("GetXResolution = %v, %v", v, ok)
+	}
+	if !setter.SetSoftware("tiff") {
+		t.Fatal("SetSoftware failed")
+	}
+	if v, ok := getter.GetSoftware(); !ok || v != "tiff" || opt.EntryMap[TagType_Software].Count != 5 {
+		t.Fatalf("GetSoftware = %q, %v", v, ok)
+	}
+	date := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC)
+	if !setter.SetDateTime(date) {
+		t.Fatal("SetDateTime failed")
+	}
+	if v, ok := getter.GetDateTime(); !ok || !v.Equal(date) {
+		t.Fatalf("GetDateTime = %v, %v", v, ok)
+	}
+	if !setter.SetModelPixelScaleTag([]float64{0.5, 0.5, 0}) {
+		t.Fatal("SetModelPixelScaleTag failed")
+	}
+	if v, ok := getter.GetModelPixelScaleTag(); !ok || !reflect.DeepEqual(v, []float64{0.5, 0.5, 0}) {
+		t.Fatalf("GetModelPixelScaleTag = %v, %v", v, ok)
+	}
+	if !setter.SetExtraSamples([]int64{2, 0}) {
+		t.Fatal("SetExtraSamples failed")
+	}
+	if v, ok := getter.GetExtraSamples(); !ok || !reflect.DeepEqual(v, []int64{2, 0}) || opt.EntryMap[TagType_ExtraSamples].DataType != DataType_Short {
+		t.Fatalf("GetExtraSamples = %v, %v", v, ok)
+	}
+	if !setter.SetDotRange([]int64{0, 200}) {
+		t.Fatal("SetDotRange failed")
+	}
+	if v, ok := getter.GetDotRange(); !ok || !reflect.DeepEqual(v, []int64{0, 200}) || opt.EntryMap[TagType_DotRange].DataType != DataType_Byte {
+		t.Fatalf("GetDotRange = %v, %v", v, ok)
+	}
+	if !setter.SetBitsPerSample([]int64{2}) {
+		t.Fatal("SetBitsPerSample failed")
+	}
+	palette := [][3]uint16{{0, 0, 0}, {1, 2, 3}, {4, 5, 6}, {0xffff, 0xffff, 0xffff}}
+	if !setter.SetColorMap(palette) {
+		t.Fatal("SetColorMap failed")
+	}
+	if v, ok := getter.GetColorMap(); !ok || !reflect.DeepEqual(v, palette) {
+		t.Fatalf("GetColorMap = %v, %v", v, ok)
+	}
+	if !setter.SetUnknown(TagType(65000), []byte("private")) {
+		t.Fatal("SetUnknown failed")
+	}
+	if v, ok := getter.GetUnknown(TagType(65000)); !ok || string(v) != "private" {
+		t.Fatalf("GetUnknown = %q, %v", v, ok)
+	}
+
+	// Values which do not fit are rejected, leaving the entries as is.
+	for name, ok := range map[string]bool{
+		"too many values":   setter.SetPageNumber([]int64{1, 2, 3}),
+		"no value":          setter.SetBitsPerSample(nil),
+		"out of range":      setter.SetOrientation(1 << 16),
+		"too large":         setter.SetImageWidth(1 << 32),
+		"zero denominator":  setter.SetYResolution([2]int64{300, 0}),
+		"wrong palette":     setter.SetColorMap(palette[:2]),
+		"wrong type":        setter.SetUnknown(TagType_ImageDescription, int64(1)),
+		"unsupported value": setter.SetUnknown(TagType(65001), struct{}{}),
+	} {
+		if ok {
+			t.Errorf("%s: setter succeeded", name)
+		}
+	}
+	if _, ok := opt.EntryMap[TagType_YResolution]; ok {
+		t.Fatal("SetYResolution added an invalid entry")
+	}
+	if v, _ := getter.GetImageWidth(); v != 100 {
+		t.Fatalf("GetImageWidth = %v, want 100", v)
+	}
+
+	// BigTIFF entries use 64-bit values when needed.
+	ifd := &IFD{Header: NewHeader(true, 16)}
+	if !ifd.TagSetter().SetStripOffsets([]int64{8, 1 << 40}) {
+		t.Fatal("SetStripOffsets failed")
+	}
+	if dt := ifd.EntryMap[TagType_StripOffsets].DataType; dt != DataType_Long8 {
+		t.Fatalf("StripOffsets: data type %v, want %v", dt, DataType_Long8)
+	}
+}
+
+func TestEncodeWithTagSetter(t *testing.T) {
+	m := image.NewGray(image.Rect(0, 0, 64, 48))
+	for i := range m.Pix {
+		m.Pix[i] = uint8(i)
+	}
+
+	var opt Options
+	setter := opt.TagSetter()
+	setter.SetCompression(TagValue_CompressionType_Deflate)
+	setter.SetTileWidth(32)
+	setter.SetTileLength(32)
+
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, m, &opt); err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ifd := p.Ifd[0][0]
+	if ifd.Compression() != TagValue_CompressionType_Deflate || ifd.BlocksAcross() != 2 || ifd.BlocksDown() != 2 {
+		t.Fatalf("compression %v, %dx%d blocks", ifd.Compression(), ifd.BlocksAcross(), ifd.BlocksDown())
+	}
+	got, err := p.DecodeImage(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.(*image.Gray).Pix, m.Pix) {
+		t.Fatal("decoded pixels differ")
+	}
+}
+
+func TestExifGPS(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "gdal_autotest/gcore/data/exif_and_gps.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ifd := f.Ifd[0][0]
+	if ifd.Exif == nil || ifd.GPS == nil {
+		t.Fatalf("Exif = %v, GPS = %v, want both", ifd.Exif, ifd.GPS)
+	}
+	if v, ok := ifd.Exif.GetString(ExifIFD_TagType_SpectralSensitivity); !ok || v != "EXIF Spectral Sensitivity" {
+		t.Fatalf("SpectralSensitivity = %q, %v", v, ok)
+	}
+	if _, ok := ifd.Exif.ExposureTime(); ok {
+		t.Fatalf("ExposureTime: got a missing entry")
+	}
+	if v, ok := ifd.GPS.Latitude(); !ok || v < -77.1-1e-9 || v > -77.1+1e-9 {
+		t.Fatalf("Latitude = %v, %v, want -77.1", v, ok)
+	}
+	if v, ok := ifd.GPS.Longitude(); !ok || v < 34.2-1e-9 || v > 34.2+1e-9 {
+		t.Fatalf("Longitude = %v, %v, want 34.2", v, ok)
+	}
+
+	data, err = ioutil.ReadFile(testdataDir + "misc/exif_camera.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	exif, gps := f.Ifd[0][0].Exif, f.Ifd[0][0].GPS
+	if v, ok := exif.DateTimeOriginal(); !ok || !v.Equal(time.Date(2014, 5, 6, 7, 8, 9, 250e6, time.UTC)) {
+		t.Fatalf("DateTimeOriginal = %v, %v, want 2014-05-06 07:08:09.25", v, ok)
+	}
+	if v, ok := exif.ExposureTime(); !ok || v != 1.0/250 {
+		t.Fatalf("ExposureTime = %v, %v, want 1/250", v, ok)
+	}
+	if v, ok := exif.FNumber(); !ok || v != 2.8 {
+		t.Fatalf("FNumber = %v, %v, want 2.8", v, ok)
+	}
+	if v, ok := exif.ISOSpeedRatings(); !ok || v != 200 {
+		t.Fatalf("ISOSpeedRatings = %v, %v, want 200", v, ok)
+	}
+	if v, ok := exif.InteroperabilityIndex(); !ok || v != "R98" {
+		t.Fatalf("InteroperabilityIndex = %q, %v, want R98", v, ok)
+	}
+	if v, ok := gps.Latitude(); !ok || v < 48.8582-1e-4 || v > 48.8582+1e-4 {
+		t.Fatalf("Latitude = %v, %v, want 48.8582", v, ok)
+	}
+	if v, ok := gps.Time(); !ok || !v.Equal(time.Date(2014, 5, 6, 12, 30, 15, 0, time.UTC)) {
+		t.Fatalf("Time = %v, %v, want 2014-05-06 12:30:15", v, ok)
+	}
+
+	// The rationals with a zero denominator have no value.
+	if v, ok := exif.FocalLength(); ok {
+		t.Fatalf("FocalLength = %v: got a value from 50/0", v)
+	}
+	if v, ok := gps.Altitude(); ok {
+		t.Fatalf("Altitude = %v: got a value from 35/0", v)
+	}
+
+	// The accessors of a missing IFD return false.
+	exif = nil
+	if _, ok := exif.DateTimeOriginal(); ok {
+		t.Fatalf("DateTimeOriginal: got a value from a nil ExifIFD")
+	}
+
+	want := time.Date(2014, 5, 6, 7, 8, 9, 250e6, time.UTC)
+	if v, ok := parseExifTime("2014:05:06 07:08:09", "25"); !ok || !v.Equal(want) {
+		t.Fatalf("parseExifTime = %v, %v, want %v", v, ok, want)
+	}
+}
+
+func TestGeoKeyDirectory(t *testing.T) {
+	openIFD := func(name string) *IFD {
+		data, err := ioutil.ReadFile(testdataDir + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		return p.Ifd[0][0]
+	}
+
+	dir, err := openIFD("geotiff/gdal_eg/cea.tif").GeoKeyDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dir.ModelType(); !ok || v != GeoKeyValue_ModelType_Projected {
+		t.Fatalf("ModelType = %v, %v", v, ok)
+	}
+	if v, ok := dir.RasterType(); !ok || v != GeoKeyValue_RasterPixelIsArea {
+		t.Fatalf("RasterType = %v, %v", v, ok)
+	}
+	if v, ok := dir.ProjectedCSType(); !ok || v != GeoKeyValue_UserDefined {
+		t.Fatalf("ProjectedCSType = %v, %v", v, ok)
+	}
+	if v, ok := dir.GeographicType(); !ok || v != 4267 {
+		t.Fatalf("GeographicType = %v, %v, want 4267", v, ok)
+	}
+	if v, ok := dir.Citation(); !ok || v != "unnamed" {
+		t.Fatalf("Citation = %q, %v", v, ok)
+	}
+	if v, ok := dir.GetString(GeoKeyType_GeogCitationGeoKey); !ok || v != "NAD27" {
+		t.Fatalf("GeogCitationGeoKey = %q, %v", v, ok)
+	}
+	if v, ok := dir.GetFloat(GeoKeyType_ProjStdParallel1GeoKey); !ok || v != 33.75 {
+		t.Fatalf("ProjStdParallel1GeoKey = %v, %v", v, ok)
+	}
+	if _, ok := dir.GetFloat(GeoKeyType_ProjectedCSTypeGeoKey); ok {
+		t.Fatalf("GetFloat: got the SHORT ProjectedCSTypeGeoKey")
+	}
+
+	dir, err = openIFD("geotiff/intergraph/brit_nat.tif").GeoKeyDirectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dir.ProjectedCSType(); !ok || v != 27700 {
+		t.Fatalf("ProjectedCSType = %v, %v, want 27700", v, ok)
+	}
+
+	// A plain TIFF has no GeoKey directory.
+	dir, err = openIFD("video-001.tiff").GeoKeyDirectory()
+	if dir != nil || err != nil {
+		t.Fatalf("GeoKeyDirectory = %v, %v, want nil, nil", dir, err)
+	}
+	if _, ok := dir.ModelType(); ok {
+		t.Fatalf("ModelType: got a value from a nil GeoKeyDirectory")
+	}
+
+	// The references out of the params are errors.
+	for _, keys := range [][]int64{
+		{1, 1, 0},
+		{2, 1, 0, 0},
+		{1, 1, 0, 2, 1024, 0, 1, 1},
+		{1, 1, 0, 1, 3078, int64(TagType_GeoDoubleParamsTag), 1, 1},
+		{1, 1, 0, 1, 1026, int64(TagType_GeoAsciiParamsTag), 8, 0},
+	} {
+		if _, err := parseGeoKeyDirectory(keys, []float64{1}, "NAD27|"); err == nil {
+			t.Errorf("parseGeoKeyDirectory(%v): no error", keys)
+		}
+	}
+}
+
+func TestGeoTransform(t *testing.T) {
+	near := func(a, b GeoTransform) bool {
+		for i := range a {
+			if d := a[i] - b[i]; d < -1e-6 || d > 1e-6 {
+				return false
+			}
+		}
+		return true
+	}
+	for _, tt := range []struct {
+		filename string
+		want     GeoTransform
+	}{
+		// ModelPixelScaleTag and ModelTiepointTag.
+		{"geotiff/gdal_eg/cea.tif", GeoTransform{-28493.166784412522, 60.02213698319374, 0, 4255884.5438021915, 0, -60.02213698319374}},
+		// A rotated ModelTransformationTag.
+		{"geotiff/zi_imaging/image0.tif", GeoTransform{-12.51100000000001, 0, 0.1850509803921595, 109.03599999999999, -0.3001842105263167, 0}},
+	} {
+		data, err := ioutil.ReadFile(testdataDir + tt.filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		gt, ok := p.Ifd[0][0].GeoTransform()
+		if !ok || !near(gt, tt.want) {
+			t.Fatalf("%s: GeoTransform = %v, %v, want %v", tt.filename, gt, ok, tt.want)
+		}
+		x, y := gt.PixelToModel(10, 20)
+		col, row, ok := gt.ModelToPixel(x, y)
+		if !ok || col < 10-1e-6 || col > 10+1e-6 || row < 20-1e-6 || row > 20+1e-6 {
+			t.Fatalf("%s: ModelToPixel(PixelToModel(10, 20)) = %v, %v, %v", tt.filename, col, row, ok)
+		}
+	}
+
+	// The tiepoint of a PixelIsPoint image is the center of the pixel.
+	ifd := &IFD{Header: NewHeader(false, 8)}
+	setter := ifd.TagSetter()
+	setter.SetModelPixelScaleTag([]float64{2, 3, 0})
+	setter.SetModelTiepointTag([]float64{0, 0, 0, 100, 200, 0})
+	gt, ok := ifd.GeoTransform()
+	if want := (GeoTransform{100, 2, 0, 200, 0, -3}); !ok || gt != want {
+		t.Fatalf("PixelIsArea: GeoTransform = %v, %v, want %v", gt, ok, want)
+	}
+	setter.SetGeoKeyDirectoryTag([]int64{1, 1, 0, 1, int64(GeoKeyType_GTRasterTypeGeoKey), 0, 1, int64(GeoKeyValue_RasterPixelIsPoint)})
+	gt, ok = ifd.GeoTransform()
+	if want := (GeoTransform{99, 2, 0, 201.5, 0, -3}); !ok || gt != want {
+		t.Fatalf("PixelIsPoint: GeoTransform = %v, %v, want %v", gt, ok, want)
+	}
+	if x, y := gt.PixelToModel(0.5, 0.5); x != 100 || y != 200 {
+		t.Fatalf("PixelToModel(0.5, 0.5) = %v, %v, want 100, 200", x, y)
+	}
+
+	// Ground control points are not an affine transform.
+	setter.SetModelTiepointTag([]float64{0, 0, 0, 100, 200, 0, 4, 4, 0, 108, 188, 0})
+	if _, ok := ifd.GeoTransform(); ok {
+		t.Fatal("GeoTransform: got a transform from several tiepoints")
+	}
+	if _, _, ok := (GeoTransform{}).ModelToPixel(0, 0); ok {
+		t.Fatal("ModelToPixel: inverted a singular transform")
+	}
+}
+
+func TestGDALMetadata(t *testing.T) {
+	openReader := func(name string) *Reader {
+		data, err := ioutil.ReadFile(testdataDir + "gdal_autotest/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := OpenReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	p := openReader("gcore/data/oddsize_1bit2b.tif")
+	md, err := p.Ifd[0][0].GDALMetadata()
+	p.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := md.Band(1); !reflect.DeepEqual(v, map[string]string{"NBITS": "1"}) {
+		t.Fatalf("Band(1) = %v", v)
+	}
+	if v := md.Dataset(); len(v) != 0 {
+		t.Fatalf("Dataset() = %v, want no item", v)
+	}
+
+	// The nodata values, and the masked float samples.
+	for _, tt := range []struct {
+		filename string
+		nodata   float64
+		want     []float64
+	}{
+		{"gcore/data/minfloat.tif", -math.MaxFloat32, []float64{math.NaN(), -3, 5, math.NaN()}},
+		{"gcore/data/stats_nodata_neginf_msvc.tif", math.Inf(-1), []float64{math.NaN(), 1, -2}},
+		{"alg/data/2by2.tif", -1.7e308, []float64{3.5, 11.5, 5.5, 13.5}},
+	} {
+		p := openReader(tt.filename)
+		defer p.Close()
+		if v, ok := p.Ifd[0][0].GDALNoData(); !ok || v != tt.nodata {
+			t.Fatalf("%s: GDALNoData = %v, %v, want %v", tt.filename, v, ok, tt.nodata)
+		}
+		m, err := p.DecodeImageWithOptions(0, 0, &DecodeOptions{KeepRawSamples: true, MaskNoData: true})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.filename, err)
+		}
+		mp := m.(*MemPImage)
+		for i, want := range tt.want {
+			got := PixSlice(mp.XPix).Value(i, mp.XDataType)
+			if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+				t.Fatalf("%s: sample %d = %v, want %v", tt.filename, i, got, want)
+			}
+		}
+	}
+	if _, ok := parseGDALFloat("1.#QNAN"); !ok {
+		t.Fatal(`parseGDALFloat("1.#QNAN") failed`)
+	}
+
+	// Write both tags.
+	md = &GDALMetadata{}
+	md.Set(-1, "AREA_OR_POINT", "Area")
+	md.SetScale(0, 0.01)
+	md.SetOffset(0, -273.15)
+	md.SetDescription(0, "Temperature <K>")
+	md.Set(0, "STATISTICS_MEAN", "8.5")
+	var opt Options
+	if !opt.SetGDALMetadata(md) || !opt.SetGDALNoData(math.Inf(-1)) {
+		t.Fatal("SetGDALMetadata or SetGDALNoData failed")
+	}
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, image.NewGray(image.Rect(0, 0, 4, 4)), &opt); err != nil {
+		t.Fatal(err)
+	}
+	p, err = OpenReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	got, err := p.Ifd[0][0].GDALMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, md) {
+		t.Fatalf("GDALMetadata = %+v, want %+v", got, md)
+	}
+	if v, ok := got.Scale(0); !ok || v != 0.01 {
+		t.Fatalf("Scale(0) = %v, %v", v, ok)
+	}
+	if v, ok := got.Offset(0); !ok || v != -273.15 {
+		t.Fatalf("Offset(0) = %v, %v", v, ok)
+	}
+	if v, ok := got.Description(0); !ok || v != "Temperature <K>" {
+		t.Fatalf("Description(0) = %q, %v", v, ok)
+	}
+	if v := got.Dataset(); !reflect.DeepEqual(v, map[string]string{"AREA_OR_POINT": "Area"}) {
+		t.Fatalf("Dataset() = %v", v)
+	}
+	if v, ok := p.Ifd[0][0].GDALNoData(); !ok || !math.IsInf(v, -1) {
+		t.Fatalf("GDALNoData = %v, %v, want -Inf", v, ok)
+	}
+
+	// The band properties of the other domains are not the band's.
+	md = &GDALMetadata{Items: []GDALMetadataItem{{Name: "SCALE", Value: "2", Sample: 0, Role: GDALMetadataRole_Scale, Domain: "IMAGERY"}}}
+	if v, ok := md.Scale(0); ok {
+		t.Fatalf("Scale(0) = %v: got the scale of another domain", v)
+	}
+
+	// A nil *GDALMetadata is not written.
+	md = nil
+	if s := md.String(); s != "" {
+		t.Fatalf("String() = %q, want \"\"", s)
+	}
+	if opt.SetGDALMetadata(nil) {
+		t.Fatal("SetGDALMetadata(nil) succeeded")
+	}
+}
+
+func TestXMP(t *testing.T) {
+	data, err := ioutil.ReadFile(testdataDir + "gdal_autotest/gdrivers/data/byte_with_xmp.tif")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := OpenReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ifd := p.Ifd[0][0]
+	if packet, ok := ifd.XMP(); !ok || !bytes.HasPrefix(packet, []byte("<?xpacket begin=")) {
+		t.Fatalf("XMP = %.20q, %v", packet, ok)
+	}
+	if packet, _ := ifd.XMP(); len(packet) != 0 {
+		packet[0] = 'x'
+		if again, _ := ifd.XMP(); again[0] != '<' {
+			t.Fatal("XMP returned the data of the entry")
+		}
+	}
+	md, err := ifd.XMPMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := md.Title(); !ok || v != "Title" {
+		t.Fatalf("Title = %q, %v", v, ok)
+	}
+	if v, ok := md.Description(); !ok || v != "Description" {
+		t.Fatalf("Description = %q, %v", v, ok)
+	}
+	if v, ok := md.Subject(); !ok || !reflect.DeepEqual(v, []string{"XMP", "Test"}) {
+		t.Fatalf("Subject = %q, %v", v, ok)
+	}
+	if _, ok := md.CreatorTool(); ok {
+		t.Fatal("CreatorTool: got a missing property")
+	}
+
+	// Embed a packet using other prefixes and the attribute form.
+	packet := []byte(`<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about="" xmlns:xap="http://ns.adobe.com/xap/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xap:CreatorTool="tiff" xap:CreateDate="2014-05-06T07:08:09Z">
+   <dc:creator><rdf:Seq><rdf:li>A</rdf:li><rdf:li>B &amp; C</rdf:li></rdf:Seq></dc:creator>
+   <dc:rights>
+    <rdf:Alt>
+     <rdf:li xml:lang="fr">Tous droits réservés</rdf:li>
+     <rdf:li xml:lang="x-default">All rights reserved</rdf:li>
+    </rdf:Alt>
+   </dc:rights>
+   <dc:format>image/tiff</dc:format>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`)
+	var opt Options
+	if !opt.SetXMP(packet) {
+		t.Fatal("SetXMP failed")
+	}
+	out := NewWriteAtBuffer(nil)
+	if err := Encode(out, image.NewGray(image.Rect(0, 0, 4, 4)), &opt); err != nil {
+		t.Fatal(err)
+	}
+	p, err = OpenReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ifd = p.Ifd[0][0]
+	if v, ok := ifd.XMP(); !ok || !bytes.Equal(v, packet) || ifd.EntryMap[TagType_XMP].DataType != DataType_Byte {
+		t.Fatalf("XMP = %q, %v", v, ok)
+	}
+	md, err = ifd.XMPMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"xmp:CreatorTool": {"tiff"},
+		"xmp:CreateDate":  {"2014-05-06T07:08:09Z"},
+		"dc:creator":      {"A", "B & C"},
+		"dc:rights":       {"All rights reserved", "Tous droits réservés"},
+		"dc:format":       {"image/tiff"},
+	}
+	if !reflect.DeepEqual(md.Properties, want) {
+		t.Fatalf("Properties = %q, want %q", md.Properties, want)
+	}
+	if v, ok := md.Rights(); !ok || v != "All rights reserved" {
+		t.Fatalf("Rights = %q, %v", v, ok)
+	}
+
+	if _, err := parseXMP([]byte("<x:xmpmeta><rdf:RDF>")); err == nil {
+		t.Fatal("parseXMP: no error for a truncated packet")
+	}
+}
@@ -71,6 +71,8 @@ type (
 	TagValue_SubfileType        TagType
 	TagValue_CompressionType    TagType
 	TagValue_PhotometricType    TagType
+	TagValue_FillOrderType      TagType
+	TagValue_OrientationType    TagType
 	TagValue_PredictorType      TagType
 	TagValue_ResolutionUnitType TagType
 	TagValue_SampleFormatType   TagType
@@ -127,12 +129,26 @@ const (
 	TagType_CellWidth                         TagType                     = 264   // SHORT, 1,
 	TagType_CellLenght                        TagType                     = 265   // SHORT, 1,
 	TagType_FillOrder                         TagType                     = 266   // SHORT, 1, # Default=1
+	_                                                                     = 0     //
+	TagValue_FillOrderType_MSB2LSB            TagValue_FillOrderType      = 1     // # most significant bit first
+	TagValue_FillOrderType_LSB2MSB            TagValue_FillOrderType      = 2     // # least significant bit first
+	_                                                                     = 0     //
 	TagType_DocumentName                      TagType                     = 269   // ASCII
 	TagType_ImageDescription                  TagType                     = 270   // ASCII
 	TagType_Make                              TagType                     = 271   // ASCII
 	TagType_Model                             TagType                     = 272   // ASCII
 	TagType_StripOffsets                      TagType                     = 273   // SHORT/LONG/LONG8, *, # StripsPerImage
 	TagType_Orientation                       TagType                     = 274   // SHORT, 1, # Default=1
+	_                                                                     = 0     //
+	TagValue_OrientationType_TopLeft          TagValue_OrientationType    = 1     // # row 0 top, col 0 left
+	TagValue_OrientationType_TopRight         TagValue_OrientationType    = 2     // # row 0 top, col 0 right
+	TagValue_OrientationType_BottomRight      TagValue_OrientationType    = 3     // # row 0 bottom, col 0 right
+	TagValue_OrientationType_BottomLeft       TagValue_OrientationType    = 4     // # row 0 bottom, col 0 left
+	TagValue_OrientationType_LeftTop          TagValue_OrientationType    = 5     // # row 0 left, col 0 top
+	TagValue_OrientationType_RightTop         TagValue_OrientationType    = 6     // # row 0 right, col 0 top
+	TagValue_OrientationType_RightBottom      TagValue_OrientationType    = 7     // # row 0 right, col 0 bottom
+	TagValue_OrientationType_LeftBottom       TagValue_OrientationType    = 8     // # row 0 left, col 0 bottom
+	_                                                                     = 0     //
 	TagType_SamplesPerPixel                   TagType                     = 277   // SHORT, 1, # Default=1
 	TagType_RowsPerStrip                      TagType                     = 278   // SHORT/LONG/LONG8, 1,
 	TagType_StripByteCounts                   TagType                     = 279   // SHORT/LONG/LONG8, *, # StripsPerImage
@@ -184,7 +200,7 @@ const (
 	TagType_NumberOfInks                      TagType                     = 334   // SHORT, 1, # Default=4
 	TagType_DotRange                          TagType                     = 336   // BYTE/SHORT, # Default=[0,2^BitsPerSample-1]
 	TagType_TargetPrinter                     TagType                     = 337   // ASCII
-	TagType_ExtraSamples                      TagType                     = 338   // BYTE,  1,
+	TagType_ExtraSamples                      TagType                     = 338   // SHORT, *,
 	TagType_SampleFormat                      TagType                     = 339   // SHORT, *, # SamplesPerPixel. Default=1
 	_                                                                     = 0     //
 	TagValue_SampleFormatType_Uint            TagValue_SampleFormatType   = 1     //
@@ -199,7 +215,7 @@ const (
 	TagType_XClipPathUnits                    TagType                     = 344   // ingore # The number of units that span the width of the image, in terms of integer ClipPath coordinates.
 	TagType_YClipPathUnits                    TagType                     = 345   // ingore # The number of units that span the height of the image, in terms of integer ClipPath coordinates.
 	TagType_Indexed                           TagType                     = 346   // ingore # Aims to broaden the support for indexed images to include support for any color space.
-	TagType_JPEGTables                        TagType                     = 347   // ingore # JPEG quantization and/or Huffman tables.
+	TagType_JPEGTables                        TagType                     = 347   // UNDEFINED # JPEG quantization and/or Huffman tables.
 	TagType_OPIProxy                          TagType                     = 351   // ingore # OPI-related.
 	TagType_GlobalParametersIFD               TagType                     = 400   // ingore # Used in the TIFF-FX standard to point to an IFD containing tags that are globally applicable to the complete TIFF file.
 	TagType_ProfileType                       TagType                     = 401   // ingore # Used in the TIFF-FX standard, denotes the type of data stored in this file or IFD.
@@ -223,7 +239,7 @@ const (
 	TagType_YCbCrPositioning                  TagType                     = 531   // SHORT, 1, # Default=1
 	TagType_ReferenceBlackWhite               TagType                     = 532   // LONG , *, # 2*SamplesPerPixel
 	TagType_StripRowCounts                    TagType                     = 559   // ingore # Defined in the Mixed Raster Content part of RFC 2301, used to replace RowsPerStrip for IFDs with variable-sized strips.
-	TagType_XMP                               TagType                     = 700   // ingore # XML packet containing XMP metadata
+	TagType_XMP                               TagType                     = 700   // BYTE/UNDEFINED # XML packet containing XMP metadata
 	TagType_ImageID                           TagType                     = 32781 // ingore # OPI-related.
 	TagType_ImageLayer                        TagType                     = 34732 // ingore # Defined in the Mixed Raster Content part of RFC 2301, used to denote the particular function of this Image in the mixed raster scheme.
 	TagType_Copyright                         TagType                     = 33432 // ASCII
@@ -0,0 +1,201 @@
+// Copyright 2014 <chaishushan{AT}gmail.com>. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tiff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The XML namespaces of the XMP properties.
+const (
+	xmpNamespaceRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmpNamespaceDC  = "http://purl.org/dc/elements/1.1/"
+	xmpNamespaceXMP = "http://ns.adobe.com/xap/1.0/"
+	xmlNamespace    = "http://www.w3.org/XML/1998/namespace"
+)
+
+// xmpPrefixes are the prefixes of the properties read by XMPMetadata.
+var xmpPrefixes = map[string]string{
+	xmpNamespaceDC:  "dc",
+	xmpNamespaceXMP: "xmp",
+}
+
+// XMPMetadata holds the Dublin Core (dc:) and XMP basic (xmp:) properties
+// of an XMP packet. The accessors may be called on a nil *XMPMetadata,
+// they return false.
+type XMPMetadata struct {
+	// Properties holds the values of the properties, by their name with
+	// the usual prefix, such as "dc:subject", whatever the prefix used in
+	// the packet. A simple property has a single value, an array (rdf:Bag,
+	// rdf:Seq or rdf:Alt) has its items, with the x-default item of an
+	// rdf:Alt first.
+	Properties map[string][]string
+}
+
+// XMP returns the XMP packet of the image, an XML document.
+func (p *IFD) XMP() (packet []byte, ok bool) {
+	return p.TagGetter().GetXMP()
+}
+
+// XMPMetadata decodes the dc: and xmp: properties of the XMP packet of the
+// image. It returns nil and no error when the image has no XMP packet.
+func (p *IFD) XMPMetadata() (md *XMPMetadata, err error) {
+	packet, ok := p.XMP()
+	if !ok {
+		return
+	}
+	return parseXMP(packet)
+}
+
+// xmpName returns the prefixed name of a dc: or xmp: property, or "".
+func xmpName(name xml.Name) string {
+	if prefix, ok := xmpPrefixes[name.Space]; ok {
+		return prefix + ":" + name.Local
+	}
+	return ""
+}
+
+// parseXMP reads the properties of the rdf:Description elements of packet,
+// given as attributes or as elements.
+func parseXMP(packet []byte) (md *XMPMetadata, err error) {
+	md = &XMPMetadata{Properties: make(map[string][]string)}
+	d := xml.NewDecoder(bytes.NewReader(bytes.TrimRight(packet, "\x00")))
+
+	var (
+		name  string // The property being read, if any.
+		depth int    // The depth of the elements in the property.
+		array bool   // Whether the property is an rdf array.
+		lang  string // The xml:lang of the current rdf:li.
+		items []string
+		text  strings.Builder
+	)
+	for {
+		var tok xml.Token
+		if tok, err = d.Token(); err != nil {
+			if err == io.EOF {
+				return md, nil
+			}
+			return nil, fmt.Errorf("tiff: IFD.XMPMetadata, %v", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if name == "" {
+				if t.Name.Space == xmpNamespaceRDF && t.Name.Local == "Description" {
+					for _, attr := range t.Attr {
+						if key := xmpName(attr.Name); key != "" {
+							md.Properties[key] = []string{attr.Value}
+						}
+					}
+				} else if key := xmpName(t.Name); key != "" {
+					name, depth, array, items = key, 0, false, nil
+					text.Reset()
+				}
+				continue
+			}
+			depth++
+			if t.Name.Space != xmpNamespaceRDF {
+				continue
+			}
+			switch t.Name.Local {
+			case "Alt", "Bag", "Seq":
+				array = true
+			case "li":
+				lang = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Space == xmlNamespace && attr.Name.Local == "lang" {
+						lang = attr.Value
+					}
+				}
+				text.Reset()
+			}
+		case xml.CharData:
+			if name != "" {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if name == "" {
+				continue
+			}
+			if depth == 0 {
+				if !array {
+					items = []string{strings.TrimSpace(text.String())}
+				}
+				md.Properties[name], name = items, ""
+				continue
+			}
+			if t.Name.Space == xmpNamespaceRDF && t.Name.Local == "li" {
+				if item := strings.TrimSpace(text.String()); lang == "x-default" {
+					items = append([]string{item}, items...)
+				} else {
+					items = append(items, item)
+				}
+			}
+			depth--
+		}
+	}
+}
+
+// GetAll returns the values of the property name, such as "dc:subject".
+func (p *XMPMetadata) GetAll(name string) (values []string, ok bool) {
+	if p == nil {
+		return
+	}
+	values, ok = p.Properties[name]
+	return
+}
+
+// Get returns the value of the property name, such as "xmp:CreatorTool".
+// For an array it is the first item, the x-default one of an rdf:Alt.
+func (p *XMPMetadata) Get(name string) (value string, ok bool) {
+	if values, _ := p.GetAll(name); len(values) != 0 {
+		return values[0], true
+	}
+	return
+}
+
+// Title returns the dc:title of the resource.
+func (p *XMPMetadata) Title() (value string, ok bool) {
+	return p.Get("dc:title")
+}
+
+// Description returns the dc:description of the resource.
+func (p *XMPMetadata) Description() (value string, ok bool) {
+	return p.Get("dc:description")
+}
+
+// Rights returns the dc:rights statement of the resource.
+func (p *XMPMetadata) Rights() (value string, ok bool) {
+	return p.Get("dc:rights")
+}
+
+// Creator returns the dc:creator authors of the resource.
+func (p *XMPMetadata) Creator() (values []string, ok bool) {
+	return p.GetAll("dc:creator")
+}
+
+// Subject returns the dc:subject keywords of the resource.
+func (p *XMPMetadata) Subject() (values []string, ok bool) {
+	return p.GetAll("dc:subject")
+}
+
+// CreatorTool returns the xmp:CreatorTool, the application which created
+// the resource.
+func (p *XMPMetadata) CreatorTool() (value string, ok bool) {
+	return p.Get("xmp:CreatorTool")
+}
+
+// CreateDate returns the xmp:CreateDate of the resource, an ISO 8601 date.
+func (p *XMPMetadata) CreateDate() (value string, ok bool) {
+	return p.Get("xmp:CreateDate")
+}
+
+// ModifyDate returns the xmp:ModifyDate of the resource, an ISO 8601 date.
+func (p *XMPMetadata) ModifyDate() (value string, ok bool) {
+	return p.Get("xmp:ModifyDate")
+}
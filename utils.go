@@ -7,6 +7,8 @@ package tiff
 import (
 	"image"
 	"os"
+	"runtime"
+	"sync"
 )
 
 func Load(filename string) (m image.Image, err error) {
@@ -34,3 +36,55 @@ func minInt(a, b int) int {
 	}
 	return b
 }
+
+// parallelDo calls fn(0), ..., fn(n-1) from at most workers goroutines,
+// runtime.GOMAXPROCS(0) if workers is not positive. After an error the
+// remaining calls are skipped, and the first error is returned.
+func parallelDo(n, workers int, fn func(i int) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		mu   sync.Mutex
+		next int
+		err  error
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				i := next
+				next++
+				stop := err != nil || i >= n
+				mu.Unlock()
+				if stop {
+					return
+				}
+				if e := fn(i); e != nil {
+					mu.Lock()
+					if err == nil {
+						err = e
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return err
+}
@@ -33,6 +33,8 @@ var _ImageTypeTable = map[ImageType]string{
 	ImageType_RGB:           `ImageType_RGB`,
 	ImageType_RGBA:          `ImageType_RGBA`,
 	ImageType_NRGBA:         `ImageType_NRGBA`,
+	ImageType_CMYK:          `ImageType_CMYK`,
+	ImageType_YCbCr:         `ImageType_YCbCr`,
 }
 
 func (p ImageType) String() string {
@@ -129,7 +131,7 @@ var _TagTypeTable = map[TagType]string{
 	TagType_NumberOfInks:                 `TagType_NumberOfInks`,                 // SHORT, 1, # Default=4
 	TagType_DotRange:                     `TagType_DotRange`,                     // BYTE/SHORT, # Default=[0,2^BitsPerSample-1]
 	TagType_TargetPrinter:                `TagType_TargetPrinter`,                // ASCII
-	TagType_ExtraSamples:                 `TagType_ExtraSamples`,                 // BYTE,  1,
+	TagType_ExtraSamples:                 `TagType_ExtraSamples`,                 // SHORT, *,
 	TagType_SampleFormat:                 `TagType_SampleFormat`,                 // SHORT, *, # SamplesPerPixel. Default=1
 	TagType_SMinSampleValue:              `TagType_SMinSampleValue`,              // *,     *, # SamplesPerPixel, try double
 	TagType_SMaxSampleValue:              `TagType_SMaxSampleValue`,              // *,     *, # SamplesPerPixel, try double
@@ -138,7 +140,7 @@ var _TagTypeTable = map[TagType]string{
 	TagType_XClipPathUnits:               `TagType_XClipPathUnits`,               // ingore # The number of units that span the width of the image, in terms of integer ClipPath coordinates.
 	TagType_YClipPathUnits:               `TagType_YClipPathUnits`,               // ingore # The number of units that span the height of the image, in terms of integer ClipPath coordinates.
 	TagType_Indexed:                      `TagType_Indexed`,                      // ingore # Aims to broaden the support for indexed images to include support for any color space.
-	TagType_JPEGTables:                   `TagType_JPEGTables`,                   // ingore # JPEG quantization and/or Huffman tables.
+	TagType_JPEGTables:                   `TagType_JPEGTables`,                   // UNDEFINED # JPEG quantization and/or Huffman tables.
 	TagType_OPIProxy:                     `TagType_OPIProxy`,                     // ingore # OPI-related.
 	TagType_GlobalParametersIFD:          `TagType_GlobalParametersIFD`,          // ingore # Used in the TIFF-FX standard to point to an IFD containing tags that are globally applicable to the complete TIFF file.
 	TagType_ProfileType:                  `TagType_ProfileType`,                  // ingore # Used in the TIFF-FX standard, denotes the type of data stored in this file or IFD.
@@ -162,7 +164,7 @@ var _TagTypeTable = map[TagType]string{
 	TagType_YCbCrPositioning:             `TagType_YCbCrPositioning`,             // SHORT, 1, # Default=1
 	TagType_ReferenceBlackWhite:          `TagType_ReferenceBlackWhite`,          // LONG , *, # 2*SamplesPerPixel
 	TagType_StripRowCounts:               `TagType_StripRowCounts`,               // ingore # Defined in the Mixed Raster Content part of RFC 2301, used to replace RowsPerStrip for IFDs with variable-sized strips.
-	TagType_XMP:                          `TagType_XMP`,                          // ingore # XML packet containing XMP metadata
+	TagType_XMP:                          `TagType_XMP`,                          // BYTE/UNDEFINED # XML packet containing XMP metadata
 	TagType_ImageID:                      `TagType_ImageID`,                      // ingore # OPI-related.
 	TagType_ImageLayer:                   `TagType_ImageLayer`,                   // ingore # Defined in the Mixed Raster Content part of RFC 2301, used to denote the particular function of this Image in the mixed raster scheme.
 	TagType_Copyright:                    `TagType_Copyright`,                    // ASCII
@@ -298,9 +300,10 @@ var _TagType_TypesTable = map[TagType][]DataType{
 	TagType_NumberOfInks:                []DataType{DataType_Short},
 	TagType_DotRange:                    []DataType{DataType_Byte, DataType_Short},
 	TagType_TargetPrinter:               []DataType{DataType_ASCII},
-	TagType_ExtraSamples:                []DataType{DataType_Byte},
+	TagType_ExtraSamples:                []DataType{DataType_Short},
 	TagType_SampleFormat:                []DataType{DataType_Short},
 	TagType_TransferRange:               []DataType{DataType_Short},
+	TagType_JPEGTables:                  []DataType{DataType_Undefined},
 	TagType_JPEGProc:                    []DataType{DataType_Short},
 	TagType_JPEGInterchangeFormat:       []DataType{DataType_Long},
 	TagType_JPEGInterchangeFormatLength: []DataType{DataType_Long},
@@ -314,6 +317,7 @@ var _TagType_TypesTable = map[TagType][]DataType{
 	TagType_YCbCrSubSampling:            []DataType{DataType_Short},
 	TagType_YCbCrPositioning:            []DataType{DataType_Short},
 	TagType_ReferenceBlackWhite:         []DataType{DataType_Long},
+	TagType_XMP:                         []DataType{DataType_Byte, DataType_Undefined},
 	TagType_Copyright:                   []DataType{DataType_ASCII},
 	TagType_ModelPixelScaleTag:          []DataType{DataType_Double},
 	TagType_IrasBTransformationMatrix:   []DataType{DataType_Double},
@@ -360,7 +364,6 @@ var _TagType_NumsTable = map[TagType][]int{
 	TagType_TileLength:                  []int{1},
 	TagType_InkSet:                      []int{1},
 	TagType_NumberOfInks:                []int{1},
-	TagType_ExtraSamples:                []int{1},
 	TagType_TransferRange:               []int{6},
 	TagType_JPEGProc:                    []int{1},
 	TagType_JPEGInterchangeFormat:       []int{1},
@@ -430,18 +433,18 @@ type TagGetter interface {
 	GetNumberOfInks() (value int64, ok bool)
 	GetDotRange() (value []int64, ok bool)
 	GetTargetPrinter() (value string, ok bool)
-	GetExtraSamples() (value int64, ok bool)
+	GetExtraSamples() (value []int64, ok bool)
 	GetSampleFormat() (value []int64, ok bool)
 	GetSMinSampleValue() (value []float64, ok bool)
 	GetSMaxSampleValue() (value []float64, ok bool)
 	GetTransferRange() (value []int64, ok bool)
+	GetJPEGTables() (value []byte, ok bool)
 	GetJPEGProc() (value int64, ok bool)
 	GetJPEGInterchangeFormat() (value int64, ok bool)
 	GetJPEGInterchangeFormatLength() (value int64, ok bool)
 	GetJPEGRestartInterval() (value int64, ok bool)
 	GetJPEGLosslessPredictors() (value []int64, ok bool)
 	GetJPEGPointTransforms() (value []int64, ok bool)
-	GetJPEGTables() (value []byte, ok bool)
 	GetJPEGQTables() (value []int64, ok bool)
 	GetJPEGDCTables() (value []int64, ok bool)
 	GetJPEGACTables() (value []int64, ok bool)
@@ -449,6 +452,7 @@ type TagGetter interface {
 	GetYCbCrSubSampling() (value []int64, ok bool)
 	GetYCbCrPositioning() (value int64, ok bool)
 	GetReferenceBlackWhite() (value []int64, ok bool)
+	GetXMP() (value []byte, ok bool)
 	GetCopyright() (value string, ok bool)
 	GetModelPixelScaleTag() (value []float64, ok bool)
 	GetIrasBTransformationMatrix() (value []float64, ok bool)
@@ -523,11 +527,12 @@ type TagSetter interface {
 	SetNumberOfInks(value int64) (ok bool)
 	SetDotRange(value []int64) (ok bool)
 	SetTargetPrinter(value string) (ok bool)
-	SetExtraSamples(value int64) (ok bool)
+	SetExtraSamples(value []int64) (ok bool)
 	SetSampleFormat(value []int64) (ok bool)
 	SetSMinSampleValue(value []float64) (ok bool)
 	SetSMaxSampleValue(value []float64) (ok bool)
 	SetTransferRange(value []int64) (ok bool)
+	SetJPEGTables(value []byte) (ok bool)
 	SetJPEGProc(value int64) (ok bool)
 	SetJPEGInterchangeFormat(value int64) (ok bool)
 	SetJPEGInterchangeFormatLength(value int64) (ok bool)
@@ -541,6 +546,7 @@ type TagSetter interface {
 	SetYCbCrSubSampling(value []int64) (ok bool)
 	SetYCbCrPositioning(value int64) (ok bool)
 	SetReferenceBlackWhite(value []int64) (ok bool)
+	SetXMP(value []byte) (ok bool)
 	SetCopyright(value string) (ok bool)
 	SetModelPixelScaleTag(value []float64) (ok bool)
 	SetIrasBTransformationMatrix(value []float64) (ok bool)
@@ -635,6 +641,36 @@ func (p TagValue_PhotometricType) String() string {
 	return fmt.Sprintf("TagValue_PhotometricType_Unknown(%d)", uint16(p))
 }
 
+var _TagValue_FillOrderTypeTable = map[TagValue_FillOrderType]string{
+	TagValue_FillOrderType_MSB2LSB: `TagValue_FillOrderType_MSB2LSB`, // # most significant bit first
+	TagValue_FillOrderType_LSB2MSB: `TagValue_FillOrderType_LSB2MSB`, // # least significant bit first
+}
+
+func (p TagValue_FillOrderType) String() string {
+	if name, ok := _TagValue_FillOrderTypeTable[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("TagValue_FillOrderType_Unknown(%d)", uint16(p))
+}
+
+var _TagValue_OrientationTypeTable = map[TagValue_OrientationType]string{
+	TagValue_OrientationType_TopLeft:     `TagValue_OrientationType_TopLeft`,     // # row 0 top, col 0 left
+	TagValue_OrientationType_TopRight:    `TagValue_OrientationType_TopRight`,    // # row 0 top, col 0 right
+	TagValue_OrientationType_BottomRight: `TagValue_OrientationType_BottomRight`, // # row 0 bottom, col 0 right
+	TagValue_OrientationType_BottomLeft:  `TagValue_OrientationType_BottomLeft`,  // # row 0 bottom, col 0 left
+	TagValue_OrientationType_LeftTop:     `TagValue_OrientationType_LeftTop`,     // # row 0 left, col 0 top
+	TagValue_OrientationType_RightTop:    `TagValue_OrientationType_RightTop`,    // # row 0 right, col 0 top
+	TagValue_OrientationType_RightBottom: `TagValue_OrientationType_RightBottom`, // # row 0 right, col 0 bottom
+	TagValue_OrientationType_LeftBottom:  `TagValue_OrientationType_LeftBottom`,  // # row 0 left, col 0 bottom
+}
+
+func (p TagValue_OrientationType) String() string {
+	if name, ok := _TagValue_OrientationTypeTable[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("TagValue_OrientationType_Unknown(%d)", uint16(p))
+}
+
 var _TagValue_PredictorTypeTable = map[TagValue_PredictorType]string{
 	TagValue_PredictorType_None:       `TagValue_PredictorType_None`,       //
 	TagValue_PredictorType_Horizontal: `TagValue_PredictorType_Horizontal`, //